@@ -0,0 +1,208 @@
+package sysctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ApplyOption is an option altering how configurations and plans
+// are applied.
+type ApplyOption func(*applyOptions)
+
+type applyOptions struct {
+	rollback        bool
+	continueOnError bool
+	verify          bool
+}
+
+// WithRollback makes apply transactional. Before writing anything, the
+// current value of every sysctl to be set is read. If setting any sysctl
+// fails, all sysctls already written are restored to their previous
+// value and a *RollbackError is returned.
+// Sysctls whose failures are ignored are set even if they cannot be
+// read, e.g. because they are write-only, but they are not restored.
+func WithRollback() ApplyOption {
+	return func(o *applyOptions) {
+		o.rollback = true
+	}
+}
+
+// WithContinueOnError makes apply try to set all sysctls even if setting
+// some of them fails. If any failure occurs, an *ApplyError is returned,
+// listing all failures and all sysctls successfully set.
+// If combined with WithRollback, all sysctls are tried before rolling back.
+func WithContinueOnError() ApplyOption {
+	return func(o *applyOptions) {
+		o.continueOnError = true
+	}
+}
+
+// WithVerify makes apply read back the value of each sysctl after writing
+// it, as done by Client.SetVerified. A value that differs from the one
+// written is treated as a failure to set the sysctl.
+func WithVerify() ApplyOption {
+	return func(o *applyOptions) {
+		o.verify = true
+	}
+}
+
+// ApplyError is returned when applying with WithContinueOnError
+// if setting one or more sysctls fails.
+type ApplyError struct {
+	// Applied are the keys of the sysctls successfully set,
+	// in the order in which they were set.
+	Applied []string
+	// Failed are the keys of the sysctls that could not be set,
+	// in the order in which they were tried.
+	Failed []string
+	// Errs are the errors occurred setting each sysctl in Failed.
+	Errs []error
+}
+
+func (e *ApplyError) Error() string {
+	errs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		errs[i] = err.Error()
+	}
+	return fmt.Sprintf("could not set sysctls: %s", strings.Join(errs, "; "))
+}
+
+// Unwrap returns the errors occurred setting each sysctl.
+func (e *ApplyError) Unwrap() []error {
+	return e.Errs
+}
+
+// RollbackError is returned by a transactional apply if setting a sysctl
+// fails. It reports both the original failure and any failure to restore
+// the sysctls already written.
+type RollbackError struct {
+	// Err is the error that caused the rollback.
+	Err error
+	// RollbackErrs are the errors occurred while restoring sysctls.
+	RollbackErrs []error
+}
+
+func (e *RollbackError) Error() string {
+	if len(e.RollbackErrs) == 0 {
+		return fmt.Sprintf("%v (rolled back)", e.Err)
+	}
+	errs := make([]string, len(e.RollbackErrs))
+	for i, err := range e.RollbackErrs {
+		errs[i] = err.Error()
+	}
+	return fmt.Sprintf("%v (rollback failed: %s)", e.Err, strings.Join(errs, "; "))
+}
+
+// Unwrap returns the error that caused the rollback.
+func (e *RollbackError) Unwrap() error {
+	return e.Err
+}
+
+// apply sets the values of a list of entries, in order.
+// If ctx is done before all entries are set, it stops and returns
+// a *CanceledError, after rolling back if required. Rollbacks are
+// not interrupted by ctx.
+func (c *Client) apply(ctx context.Context, entries []Entry, opts []ApplyOption) error {
+	var o applyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var (
+		previous []string
+		// saved reports whether the previous value of each entry
+		// was read, so that it can be restored
+		saved []bool
+	)
+	if o.rollback {
+		previous = make([]string, len(entries))
+		saved = make([]bool, len(entries))
+		for i, e := range entries {
+			if err := ctx.Err(); err != nil {
+				return &CanceledError{Op: "apply", Done: 0, Total: len(entries), Err: err}
+			}
+			v, err := c.Get(e.Key)
+			if err != nil {
+				if e.IgnoreFailure {
+					// the entry is still applied, but its
+					// previous value cannot be restored
+					continue
+				}
+				return err
+			}
+			previous[i], saved[i] = v, true
+		}
+	}
+	var (
+		written []int
+		aerr    ApplyError
+	)
+	for i, e := range entries {
+		if err := ctx.Err(); err != nil {
+			var cerr error = &CanceledError{Op: "apply", Done: i, Total: len(entries), Err: err}
+			if len(aerr.Errs) > 0 {
+				// do not lose failures occurred before
+				cerr = errors.Join(cerr, &aerr)
+			}
+			if o.rollback {
+				return c.rollback(cerr, entries, previous, written)
+			}
+			return cerr
+		}
+		err := c.Set(e.Key, e.Value)
+		if err == nil {
+			if o.rollback && saved[i] {
+				written = append(written, i)
+			}
+			if o.verify {
+				err = c.verify(e.Key, e.Value)
+			}
+		}
+		if err != nil {
+			if e.IgnoreFailure {
+				continue
+			}
+			err = setError(e, err)
+			if !o.continueOnError {
+				if o.rollback {
+					return c.rollback(err, entries, previous, written)
+				}
+				return err
+			}
+			aerr.Failed = append(aerr.Failed, e.Key)
+			aerr.Errs = append(aerr.Errs, err)
+			continue
+		}
+		aerr.Applied = append(aerr.Applied, e.Key)
+	}
+	if len(aerr.Errs) == 0 {
+		return nil
+	}
+	if o.rollback {
+		return c.rollback(&aerr, entries, previous, written)
+	}
+	return &aerr
+}
+
+// setError returns the error occurred setting the value of an entry
+func setError(e Entry, err error) error {
+	if e.File == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", e.Location(), err)
+}
+
+// rollback restores the previous values of all written entries,
+// in reverse order
+func (c *Client) rollback(cause error, entries []Entry, previous []string, written []int) error {
+	rerr := &RollbackError{Err: cause}
+	for i := len(written) - 1; i >= 0; i-- {
+		j := written[i]
+		key := entries[j].Key
+		if err := c.Set(key, previous[j]); err != nil {
+			rerr.RollbackErrs = append(rerr.RollbackErrs, fmt.Errorf("could not restore %s = %s: %w", key, previous[j], err))
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,489 @@
+package sysctl
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// newTestClient returns a client whose sysctls are the given files,
+// created in a temporary directory along with "ro", "wo" and "null".
+// Since root can read and write any regular file, failures are injected
+// replacing readFile and writeFile until the end of the test: writes to
+// "ro" fail as for a read-only sysctl, reads of "wo" fail as for
+// a write-only sysctl and writes to "null" succeed but are discarded,
+// as for /dev/null.
+func newTestClient(t *testing.T, files map[string]string) *Client {
+	t.Helper()
+	path := t.TempDir()
+	createConfigFiles(t, path, files)
+	createConfigFiles(t, path, map[string]string{
+		"ro":   "6.1.0",
+		"wo":   "",
+		"null": "",
+	})
+	read, write := readFile, writeFile
+	t.Cleanup(func() {
+		readFile, writeFile = read, write
+	})
+	readFile = func(root *os.Root, name string) (string, error) {
+		if filepath.Base(name) == "wo" {
+			return "", &os.PathError{Op: "openat", Path: name, Err: syscall.EACCES}
+		}
+		return read(root, name)
+	}
+	writeFile = func(root *os.Root, name, value string) error {
+		switch filepath.Base(name) {
+		case "ro":
+			return &os.PathError{Op: "openat", Path: name, Err: syscall.EACCES}
+		case "null":
+			return nil
+		}
+		return write(root, name, value)
+	}
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	return cl
+}
+
+func TestClientApplyConfigRollback(t *testing.T) {
+	cases := []struct {
+		name     string
+		entries  []Entry
+		opts     []ApplyOption
+		expected map[string]string
+		rollback bool
+		ok       bool
+	}{
+		{
+			name: "ok",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "b", Value: "20"},
+				{Key: "missing", Value: "30", IgnoreFailure: true},
+			},
+			opts: []ApplyOption{WithRollback()},
+			expected: map[string]string{
+				"a": "10",
+				"b": "20",
+			},
+			ok: true,
+		},
+		{
+			name: "no rollback",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "ro", Value: "x"},
+				{Key: "b", Value: "20"},
+			},
+			expected: map[string]string{
+				"a": "10",
+				"b": "2",
+			},
+		},
+		{
+			name: "rollback",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "b", Value: "20"},
+				{Key: "ro", Value: "x"},
+			},
+			opts: []ApplyOption{WithRollback()},
+			expected: map[string]string{
+				"a": "1",
+				"b": "2",
+			},
+			rollback: true,
+		},
+		{
+			name: "missing key",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "missing", Value: "30"},
+			},
+			opts: []ApplyOption{WithRollback()},
+			expected: map[string]string{
+				"a": "1",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl := newTestClient(t, map[string]string{
+				"a": "1",
+				"b": "2",
+			})
+			err := cl.ApplyConfig(&Config{Entries: c.entries}, c.opts...)
+			if c.ok && err != nil {
+				t.Fatalf("could not apply config: %v", err)
+			}
+			if !c.ok && err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			if err != nil {
+				t.Logf("err: %v", err)
+			}
+			var rerr *RollbackError
+			if errors.As(err, &rerr) != c.rollback {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			for k, v := range c.expected {
+				got, err := cl.Get(k)
+				if err != nil {
+					t.Fatalf("could not get key %s: %v", k, err)
+				}
+				if got != v {
+					t.Fatalf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientApplyConfigRollbackWriteOnly(t *testing.T) {
+	cases := []struct {
+		name     string
+		entries  []Entry
+		expected map[string]string
+		rollback bool
+	}{
+		{
+			name: "ok",
+			entries: []Entry{
+				{Key: "wo", Value: "x", IgnoreFailure: true},
+				{Key: "a", Value: "10"},
+			},
+			expected: map[string]string{
+				"wo": "x",
+				"a":  "10",
+			},
+		},
+		{
+			// write-only sysctls cannot be restored
+			name: "rollback",
+			entries: []Entry{
+				{Key: "wo", Value: "x", IgnoreFailure: true},
+				{Key: "a", Value: "10"},
+				{Key: "ro", Value: "x"},
+			},
+			expected: map[string]string{
+				"wo": "x",
+				"a":  "1",
+			},
+			rollback: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl := newTestClient(t, map[string]string{
+				"a": "1",
+			})
+			err := cl.ApplyConfig(&Config{Entries: c.entries}, WithRollback())
+			if err != nil {
+				t.Logf("err: %v", err)
+			}
+			var rerr *RollbackError
+			if errors.As(err, &rerr) != c.rollback {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rerr != nil && len(rerr.RollbackErrs) > 0 {
+				t.Fatalf("unexpected rollback errors: %v", rerr.RollbackErrs)
+			}
+			// read files directly, since write-only sysctls
+			// cannot be read through the client
+			for k, v := range c.expected {
+				got, err := os.ReadFile(filepath.Join(cl.path, k))
+				if err != nil {
+					t.Fatalf("could not read key %s: %v", k, err)
+				}
+				if string(got) != v {
+					t.Fatalf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientApplyConfigContinueOnError(t *testing.T) {
+	cases := []struct {
+		name     string
+		entries  []Entry
+		opts     []ApplyOption
+		applied  []string
+		failed   []string
+		expected map[string]string
+	}{
+		{
+			name: "continue on error",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "ro", Value: "x"},
+				{Key: "x.missing", Value: "30"},
+				{Key: "b", Value: "20"},
+			},
+			opts:    []ApplyOption{WithContinueOnError()},
+			applied: []string{"a", "b"},
+			failed:  []string{"ro", "x.missing"},
+			expected: map[string]string{
+				"a": "10",
+				"b": "20",
+			},
+		},
+		{
+			name: "continue on error with rollback",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "ro", Value: "x"},
+				{Key: "b", Value: "20"},
+			},
+			opts:    []ApplyOption{WithContinueOnError(), WithRollback()},
+			applied: []string{"a", "b"},
+			failed:  []string{"ro"},
+			expected: map[string]string{
+				"a": "1",
+				"b": "2",
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl := newTestClient(t, map[string]string{
+				"a": "1",
+				"b": "2",
+			})
+			err := cl.ApplyConfig(&Config{Entries: c.entries}, c.opts...)
+			if err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			t.Logf("err: %v", err)
+			var aerr *ApplyError
+			if !errors.As(err, &aerr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if diff := cmp.Diff(c.applied, aerr.Applied); diff != "" {
+				t.Fatalf("unexpected applied keys (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(c.failed, aerr.Failed); diff != "" {
+				t.Fatalf("unexpected failed keys (-want +got):\n%s", diff)
+			}
+			if !errors.Is(err, fs.ErrPermission) {
+				t.Fatal("expected error to wrap fs.ErrPermission")
+			}
+			for k, v := range c.expected {
+				got, err := cl.Get(k)
+				if err != nil {
+					t.Fatalf("could not get key %s: %v", k, err)
+				}
+				if got != v {
+					t.Fatalf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientApplyConfigVerify(t *testing.T) {
+	cl := newTestClient(t, map[string]string{
+		"a": "1",
+		"b": "2",
+	})
+	config := &Config{
+		Entries: []Entry{
+			{Key: "a", Value: "10"},
+			{Key: "null", Value: "1"},
+			{Key: "b", Value: "20"},
+		},
+	}
+	if err := cl.ApplyConfig(config); err != nil {
+		t.Fatalf("could not apply config without verification: %v", err)
+	}
+	err := cl.ApplyConfig(config, WithVerify(), WithRollback())
+	if err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+	t.Logf("err: %v", err)
+	var merr *MismatchError
+	if !errors.As(err, &merr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	var rerr *RollbackError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+}
+
+func TestClientLoadConfigAndApplyWith(t *testing.T) {
+	cl := newTestClient(t, map[string]string{
+		"a": "1",
+	})
+	confDir := t.TempDir()
+	createConfigFiles(t, confDir, map[string]string{
+		"sysctl.conf": "a = 10\nnull = 1\n",
+	})
+	conf := filepath.Join(confDir, "sysctl.conf")
+	if err := cl.LoadConfigAndApplyWith([]string{conf}); err != nil {
+		t.Fatalf("could not apply config without verification: %v", err)
+	}
+	err := cl.LoadConfigAndApplyWith([]string{conf}, WithVerify())
+	var merr *MismatchError
+	if !errors.As(err, &merr) {
+		t.Fatalf("expected *MismatchError, got: %v", err)
+	}
+	if merr.Key != "null" {
+		t.Fatalf("unexpected error: %+v", merr)
+	}
+}
+
+func TestClientApplyContext(t *testing.T) {
+	cases := []struct {
+		name     string
+		entries  []Entry
+		opts     []ApplyOption
+		calls    int
+		expected map[string]string
+		done     int
+		rollback bool
+		failed   []string
+	}{
+		{
+			name: "canceled",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "b", Value: "20"},
+			},
+			calls: 1,
+			expected: map[string]string{
+				"a": "10",
+				"b": "2",
+			},
+			done: 1,
+		},
+		{
+			name: "rollback",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "b", Value: "20"},
+				{Key: "c", Value: "30"},
+			},
+			opts: []ApplyOption{WithRollback()},
+			// the context is checked once for each sysctl
+			// read before writing
+			calls: 5,
+			expected: map[string]string{
+				"a": "1",
+				"b": "2",
+				"c": "3",
+			},
+			done:     2,
+			rollback: true,
+		},
+		{
+			name: "continue on error",
+			entries: []Entry{
+				{Key: "a", Value: "10"},
+				{Key: "ro", Value: "x"},
+				{Key: "b", Value: "20"},
+			},
+			opts:  []ApplyOption{WithContinueOnError()},
+			calls: 2,
+			expected: map[string]string{
+				"a": "10",
+				"b": "2",
+			},
+			done:   2,
+			failed: []string{"ro"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl := newTestClient(t, map[string]string{
+				"a": "1",
+				"b": "2",
+				"c": "3",
+			})
+			ctx := &countdownContext{Context: context.Background(), n: c.calls}
+			err := cl.ApplyConfigContext(ctx, &Config{Entries: c.entries}, c.opts...)
+			if err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			t.Logf("err: %v", err)
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected error matching context.Canceled, got: %v", err)
+			}
+			var cerr *CanceledError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected *CanceledError, got: %T", err)
+			}
+			if cerr.Done != c.done || cerr.Total != len(c.entries) {
+				t.Fatalf("unexpected progress: %d of %d", cerr.Done, cerr.Total)
+			}
+			var rerr *RollbackError
+			if errors.As(err, &rerr) != c.rollback {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			var aerr *ApplyError
+			if errors.As(err, &aerr) {
+				if diff := cmp.Diff(c.failed, aerr.Failed); diff != "" {
+					t.Fatalf("unexpected failed keys (-want +got):\n%s", diff)
+				}
+			} else if c.failed != nil {
+				t.Fatalf("expected *ApplyError, got: %T", err)
+			}
+			for k, v := range c.expected {
+				got, err := cl.Get(k)
+				if err != nil {
+					t.Fatalf("could not get key %s: %v", k, err)
+				}
+				if got != v {
+					t.Fatalf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func TestClientLoadConfigAndApplyContext(t *testing.T) {
+	path := t.TempDir()
+	createConfigFiles(t, path, map[string]string{
+		"a": "1",
+		"b": "2",
+	})
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	confDir := t.TempDir()
+	createConfigFiles(t, confDir, map[string]string{
+		"sysctl.conf": "a = 10\nb = 20\n",
+	})
+	conf := filepath.Join(confDir, "sysctl.conf")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cl.LoadConfigAndApplyContext(ctx, conf)
+	var cerr *CanceledError
+	if !errors.As(err, &cerr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected *CanceledError matching context.Canceled, got: %v", err)
+	}
+	if err := cl.LoadConfigAndApplyContext(context.Background(), conf); err != nil {
+		t.Fatalf("could not apply config: %v", err)
+	}
+	got, err := cl.GetAll()
+	if err != nil {
+		t.Fatalf("could not get values: %v", err)
+	}
+	expected := map[string]string{
+		"a": "10",
+		"b": "20",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected values (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,73 @@
+package sysctl
+
+import (
+	"context"
+	"io/fs"
+)
+
+// Backend is a store of sysctls, which a Client created with
+// NewBackendClient reads and writes instead of the files in /proc/sys.
+// It allows using a Client on sources other than the running kernel,
+// e.g. in tests.
+//
+// Keys passed to and returned by a Backend are in canonical form and
+// already validated. Errors should match fs.ErrNotExist if a sysctl does
+// not exist, fs.ErrPermission if it cannot be read or written and
+// syscall.EINVAL if a value is rejected, as errors returned by the kernel
+// do, so that they are matched by ErrNotExist, ErrPermission and
+// ErrInvalidValue. Backends that cannot be written should return errors
+// matching ErrReadOnly from Write. As for files, GetPattern skips, and
+// WalkPattern reports, sysctls whose Read fails with an error matching
+// fs.ErrPermission or with an *fs.PathError whose Op is "read".
+type Backend interface {
+	// Read returns the value of a sysctl, without leading
+	// and trailing whitespace.
+	Read(key string) (string, error)
+	// Write sets the value of a sysctl.
+	Write(key, value string) error
+	// Keys returns the keys of all sysctls.
+	Keys() ([]string, error)
+	// Mode returns the file mode of a sysctl, whose permission bits
+	// tell whether the sysctl can be read and written.
+	Mode(key string) (fs.FileMode, error)
+}
+
+// Interface is the set of methods of Client, allowing code using a
+// Client to be tested with alternative implementations, such as
+// the one provided by package sysctltest.
+type Interface interface {
+	Close() error
+	Get(key string) (string, error)
+	GetContext(ctx context.Context, key string) (string, error)
+	GetPattern(pattern string) (map[string]string, error)
+	GetPatternContext(ctx context.Context, pattern string) (map[string]string, error)
+	WalkPattern(pattern string, fn WalkFunc) error
+	WalkPatternContext(ctx context.Context, pattern string, fn WalkFunc) error
+	GetAll() (map[string]string, error)
+	GetAllContext(ctx context.Context) (map[string]string, error)
+	Set(key, value string) error
+	SetContext(ctx context.Context, key, value string) error
+	SetVerified(key, value string) error
+	GetInt(key string) (int, error)
+	GetUint64(key string) (uint64, error)
+	GetBool(key string) (bool, error)
+	GetInts(key string) ([]int, error)
+	SetInt(key string, value int) error
+	SetUint64(key string, value uint64) error
+	SetBool(key string, value bool) error
+	SetInts(key string, values []int) error
+	LoadConfigAndApply(files ...string) error
+	LoadConfigAndApplyWith(files []string, opts ...ApplyOption) error
+	LoadConfigAndApplyContext(ctx context.Context, files ...string) error
+	LoadSystemConfigAndApply() error
+	ApplyConfig(config *Config, opts ...ApplyOption) error
+	ApplyConfigContext(ctx context.Context, config *Config, opts ...ApplyOption) error
+	ResolveConfig(config *Config) ([]Entry, error)
+	PlanConfig(config *Config) (*Plan, error)
+	ApplyPlan(plan *Plan, opts ...ApplyOption) error
+	ApplyPlanContext(ctx context.Context, plan *Plan, opts ...ApplyOption) error
+	TakeSnapshot() (*Snapshot, error)
+	Restore(s *Snapshot, opts ...ApplyOption) error
+}
+
+var _ Interface = &Client{}
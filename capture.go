@@ -0,0 +1,143 @@
+package sysctl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+var (
+	// captureDeniedRe matches the warning printed by sysctl -a for
+	// sysctls that the user has no permission to read
+	captureDeniedRe = regexp.MustCompile(`^sysctl: permission denied on key ['"](.+)['"](, ignoring)?$`)
+	// captureReadErrRe matches the warning printed by sysctl -a for
+	// sysctls that could not be read for other reasons, e.g.
+	// net.ipv6.conf.all.stable_secret if not set
+	captureReadErrRe = regexp.MustCompile(`^sysctl: reading key ['"](.+)['"](: .*)?$`)
+)
+
+// NewCaptureClient returns a new read-only Client whose sysctls are parsed
+// from the output of the command sysctl -a, e.g. collected from another
+// host. The name argument is only used in error messages.
+//
+// Values spanning multiple lines, which sysctl prints as one line per
+// value line prefixed by the same key, are joined with newlines.
+// Sysctls reported by sysctl as unreadable, with lines such as
+// sysctl: permission denied on key 'x', exist but cannot be read, as if
+// they were not readable on the host and are not returned by GetPattern
+// and GetAll. Other lines starting with "sysctl: " are ignored.
+// All writes fail with an error matching ErrReadOnly.
+func NewCaptureClient(r io.Reader, name string) (*Client, error) {
+	b, err := parseCapture(r, name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBackendClient(b), nil
+}
+
+// captureBackend is a Backend storing sysctls parsed from the output
+// of sysctl -a
+type captureBackend struct {
+	values map[string]string
+	// errs are the errors reading unreadable sysctls
+	errs map[string]error
+}
+
+func parseCapture(r io.Reader, name string) (*captureBackend, error) {
+	b := &captureBackend{
+		values: make(map[string]string),
+		errs:   make(map[string]error),
+	}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if m := captureDeniedRe.FindStringSubmatch(line); m != nil {
+			b.setErr(m[1], syscall.EACCES)
+			continue
+		}
+		if m := captureReadErrRe.FindStringSubmatch(line); m != nil {
+			b.setErr(m[1], syscall.EIO)
+			continue
+		}
+		if strings.HasPrefix(line, "sysctl: ") {
+			continue
+		}
+		k, v, ok := strings.Cut(line, " =")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, &ParseError{File: name, Line: n, Text: line}
+		}
+		k = CanonicalKey(strings.TrimSpace(k))
+		if err := validateKey(k); err != nil {
+			return nil, &ParseError{File: name, Line: n, Text: line, Err: err}
+		}
+		// sysctl prints a single space between the equal sign
+		// and the value
+		v = strings.TrimPrefix(v, " ")
+		if prev, ok := b.values[k]; ok {
+			v = prev + "\n" + v
+		}
+		b.values[k] = v
+		// the value may follow an error reported for the same key,
+		// e.g. if stdout and stderr of sysctl are interleaved
+		delete(b.errs, k)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading file: %w", err)
+	}
+	for k, v := range b.values {
+		b.values[k] = strings.TrimSpace(v)
+	}
+	return b, nil
+}
+
+// setErr records an error reading a sysctl
+func (b *captureBackend) setErr(key string, err error) {
+	key = CanonicalKey(key)
+	if _, ok := b.values[key]; !ok {
+		b.errs[key] = err
+	}
+}
+
+func (b *captureBackend) Read(key string) (string, error) {
+	if v, ok := b.values[key]; ok {
+		return v, nil
+	}
+	if err, ok := b.errs[key]; ok {
+		return "", &fs.PathError{Op: "read", Path: keyToPath(key), Err: err}
+	}
+	return "", &fs.PathError{Op: "open", Path: keyToPath(key), Err: fs.ErrNotExist}
+}
+
+func (b *captureBackend) Write(key, value string) error {
+	return &fs.PathError{Op: "write", Path: keyToPath(key), Err: ErrReadOnly}
+}
+
+func (b *captureBackend) Keys() ([]string, error) {
+	keys := make([]string, 0, len(b.values)+len(b.errs))
+	for k := range b.values {
+		keys = append(keys, k)
+	}
+	for k := range b.errs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (b *captureBackend) Mode(key string) (fs.FileMode, error) {
+	if _, ok := b.values[key]; ok {
+		return 0o444, nil
+	}
+	if _, ok := b.errs[key]; ok {
+		return 0, nil
+	}
+	return 0, &fs.PathError{Op: "stat", Path: keyToPath(key), Err: fs.ErrNotExist}
+}
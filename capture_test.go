@@ -0,0 +1,160 @@
+package sysctl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewCaptureClient(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+		res    map[string]string
+		ok     bool
+	}{
+		{
+			name: "empty",
+			res:  map[string]string{},
+			ok:   true,
+		},
+		{
+			name: "values",
+			output: "kernel.hostname = host\n" +
+				"kernel.domainname = (none)\n" +
+				"net.ipv4.conf.eth0/100.rp_filter = 2\n" +
+				"net.ipv4.tcp_rmem = 4096\t131072\t6291456\n" +
+				"vm.stat_refresh = \n",
+			res: map[string]string{
+				"kernel.hostname":                  "host",
+				"kernel.domainname":                "(none)",
+				"net.ipv4.conf.eth0/100.rp_filter": "2",
+				"net.ipv4.tcp_rmem":                "4096\t131072\t6291456",
+				"vm.stat_refresh":                  "",
+			},
+			ok: true,
+		},
+		{
+			name: "multi-line",
+			output: "dev.cdrom.autoclose = 1\n" +
+				"dev.cdrom.info = CD-ROM information, Id: cdrom.c 3.20 2003/12/17\n" +
+				"dev.cdrom.info = \n" +
+				"dev.cdrom.info = drive name:\tsr0\n" +
+				"dev.cdrom.info = \n" +
+				"dev.cdrom.info = \n" +
+				"dev.cdrom.lock = 1\n",
+			res: map[string]string{
+				"dev.cdrom.autoclose": "1",
+				"dev.cdrom.info":      "CD-ROM information, Id: cdrom.c 3.20 2003/12/17\n\ndrive name:\tsr0",
+				"dev.cdrom.lock":      "1",
+			},
+			ok: true,
+		},
+		{
+			name: "unreadable",
+			output: "fs.protected_fifos = 1\r\n" +
+				"sysctl: permission denied on key 'fs.protected_hardlinks'\r\n" +
+				"sysctl: permission denied on key \"kernel.cad_pid\", ignoring\r\n" +
+				"sysctl: reading key \"net.ipv6.conf.all.stable_secret\"\r\n" +
+				"sysctl: reading key \"net.ipv6.conf.lo.stable_secret\": Input/output error\r\n" +
+				"sysctl: unknown message\r\n",
+			res: map[string]string{
+				"fs.protected_fifos": "1",
+			},
+			ok: true,
+		},
+		{
+			name: "interleaved",
+			output: "sysctl: permission denied on key 'fs.protected_fifos'\n" +
+				"fs.protected_fifos = 1\n" +
+				"fs.protected_hardlinks = 1\n",
+			res: map[string]string{
+				"fs.protected_fifos":     "1",
+				"fs.protected_hardlinks": "1",
+			},
+			ok: true,
+		},
+		{
+			name:   "invalid line",
+			output: "kernel.hostname = host\nkernel.domainname\n",
+		},
+		{
+			name:   "invalid key",
+			output: "kernel..hostname = host\n",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl, err := NewCaptureClient(strings.NewReader(c.output), "sysctl.txt")
+			if c.ok && err != nil {
+				t.Fatalf("error parsing: %v", err)
+			}
+			if !c.ok && err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			if err != nil {
+				t.Logf("err: %v", err)
+				return
+			}
+			got, err := cl.GetAll()
+			if err != nil {
+				t.Fatalf("could not get values: %v", err)
+			}
+			if diff := cmp.Diff(c.res, got); diff != "" {
+				t.Fatalf("unexpected output (-want +got):\n%s", diff)
+			}
+			keys, err := cl.backend.Keys()
+			if err != nil {
+				t.Fatalf("could not list keys: %v", err)
+			}
+			for i := 1; i < len(keys); i++ {
+				if keys[i] == keys[i-1] {
+					t.Fatalf("duplicate key: %s", keys[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCaptureClientErrors(t *testing.T) {
+	output := "kernel.hostname = host\n" +
+		"net.ipv4.ip_forward = 1\n" +
+		"sysctl: permission denied on key 'fs.protected_hardlinks'\n"
+	cl, err := NewCaptureClient(strings.NewReader(output), "sysctl.txt")
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	if v, err := cl.GetBool("net.ipv4.ip_forward"); err != nil || !v {
+		t.Fatalf("unexpected value: %v, err: %v", v, err)
+	}
+	if _, err := cl.Get("fs/protected_hardlinks"); !errors.Is(err, ErrPermission) {
+		t.Fatalf("expected error matching ErrPermission, got: %v", err)
+	}
+	if _, err := cl.Get("kernel.osrelease"); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("expected error matching ErrNotExist, got: %v", err)
+	}
+	err = cl.Set("net.ipv4.ip_forward", "0")
+	if !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected error matching ErrReadOnly, got: %v", err)
+	}
+	var kerr *KeyError
+	if !errors.As(err, &kerr) {
+		t.Fatalf("expected *KeyError, got: %T", err)
+	}
+	got, err := cl.GetPattern("^net\\.")
+	if err != nil {
+		t.Fatalf("could not get values: %v", err)
+	}
+	if diff := cmp.Diff(map[string]string{"net.ipv4.ip_forward": "1"}, got); diff != "" {
+		t.Fatalf("unexpected output (-want +got):\n%s", diff)
+	}
+	plan, err := cl.PlanConfig(&Config{Entries: []Entry{{Key: "kernel.hostname", Value: "other"}}})
+	if err != nil {
+		t.Fatalf("could not plan config: %v", err)
+	}
+	if len(plan.Changes) != 1 || !plan.Changes[0].ReadOnly {
+		t.Fatalf("expected read-only change, got: %+v", plan.Changes)
+	}
+}
@@ -1,10 +1,12 @@
 package sysctl
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -13,9 +15,9 @@ func checkExistingDir(path string) error {
 	dir, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fmt.Errorf("directory %s does not exist", path)
+			return fmt.Errorf("directory %s does not exist: %w", path, err)
 		}
-		return fmt.Errorf("could not get file info on %s: %v", path, err)
+		return fmt.Errorf("could not get file info on %s: %w", path, err)
 	}
 	if !dir.IsDir() {
 		return fmt.Errorf("path %s exists but it is not a directory", path)
@@ -23,21 +25,53 @@ func checkExistingDir(path string) error {
 	return nil
 }
 
-func readFile(path string) (string, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return "", err
+// readFile and writeFile read and write the file of a sysctl.
+// They are variables so that tests can inject failures.
+var (
+	readFile = func(root *os.Root, name string) (string, error) {
+		f, err := root.Open(name)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(f)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(data)), nil
 	}
-	return strings.TrimSpace(string(data)), nil
-}
-
-func writeFile(path, value string) error {
-	return os.WriteFile(path, []byte(value), 0o644)
-}
+	writeFile = func(root *os.Root, name, value string) error {
+		f, err := root.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write([]byte(value))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	}
+)
 
-// Client is a client for reading and writing sysctls
+// Client is a client for reading and writing sysctls.
+// Keys can be passed either in canonical form or separated by slashes,
+// as described in CanonicalKey, while keys returned are always in
+// canonical form.
+// Keys with empty or relative path components, absolute paths or NUL
+// bytes are rejected with an error matching ErrInvalidKey, and file access
+// is confined to the client path, so that symlinks cannot escape it.
 type Client struct {
 	path string
+	// backend, if set, stores sysctls instead of the files in path
+	backend Backend
+	// run, if set, runs file operations, e.g. from a thread
+	// in another namespace
+	run func(func() error) error
+	// close, if set, releases resources held by the client
+	close func() error
+	// workers, if positive, is the number of files read concurrently
+	// by GetPattern, overriding the default
+	workers int
 }
 
 // NewClient returns a new Client.
@@ -47,7 +81,7 @@ type Client struct {
 // to a different path.
 func NewClient(path string) (*Client, error) {
 	if err := checkExistingDir(path); err != nil {
-		return nil, fmt.Errorf("could not create client: %v", err)
+		return nil, fmt.Errorf("could not create client: %w", err)
 	}
 	if !strings.HasSuffix(path, "/") {
 		path += "/"
@@ -55,18 +89,89 @@ func NewClient(path string) (*Client, error) {
 	return &Client{path: path}, nil
 }
 
-func (c *Client) pathFromKey(key string) string {
-	return filepath.Join(c.path, strings.Replace(key, ".", "/", -1))
+// NewBackendClient returns a new Client reading and writing sysctls
+// from a Backend instead of files. If the backend implements io.Closer,
+// it is closed when the client is closed.
+func NewBackendClient(b Backend) *Client {
+	c := &Client{backend: b}
+	if closer, ok := b.(io.Closer); ok {
+		c.close = closer.Close
+	}
+	return c
+}
+
+// Close releases any resource held by the client.
+// It is only required for clients operating in another namespace,
+// such as those returned by NewNetNSClient, or whose backend needs
+// to be closed, and is a no-op otherwise.
+func (c *Client) Close() error {
+	if c.close == nil {
+		return nil
+	}
+	return c.close()
+}
+
+// do runs a function performing file operations
+func (c *Client) do(fn func() error) error {
+	if c.run == nil {
+		return fn()
+	}
+	return c.run(fn)
 }
 
-func (c *Client) keyFromPath(path string) string {
-	subPath := strings.TrimPrefix(path, c.path)
-	return strings.Replace(subPath, "/", ".", -1)
+// openKey validates a key and returns the client root, which the caller
+// must close, along with the path of the file of the key relative to it.
+// All file accesses go through the root, so that they are confined to it
+// even in presence of symlinks.
+func (c *Client) openKey(key string) (*os.Root, string, error) {
+	if err := validateKey(key); err != nil {
+		return nil, "", err
+	}
+	root, err := os.OpenRoot(c.path)
+	if err != nil {
+		return nil, "", err
+	}
+	return root, keyToPath(key), nil
 }
 
 // Get returns a sysctl from a given key.
+// Errors are of type *KeyError.
 func (c *Client) Get(key string) (string, error) {
-	return readFile(c.pathFromKey(key))
+	val, err := c.read(key)
+	if err != nil {
+		return "", &KeyError{Key: key, Op: "get", Err: err}
+	}
+	return val, nil
+}
+
+// GetContext is like Get, but it fails without reading the sysctl
+// if ctx is done, with a *KeyError wrapping the error of ctx.
+func (c *Client) GetContext(ctx context.Context, key string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", &KeyError{Key: key, Op: "get", Err: err}
+	}
+	return c.Get(key)
+}
+
+// read returns the value of a sysctl from the client backend
+func (c *Client) read(key string) (string, error) {
+	if c.backend != nil {
+		if err := validateKey(key); err != nil {
+			return "", err
+		}
+		return c.backend.Read(CanonicalKey(key))
+	}
+	var val string
+	err := c.do(func() error {
+		root, name, err := c.openKey(key)
+		if err != nil {
+			return err
+		}
+		defer root.Close()
+		val, err = readFile(root, name)
+		return err
+	})
+	return val, err
 }
 
 // GetPattern returns a map of sysctls matching a given pattern
@@ -74,47 +179,136 @@ func (c *Client) Get(key string) (string, error) {
 // This function matches the same sysctls that the command
 // sysctl -a -r <pattern> would return.
 func (c *Client) GetPattern(pattern string) (map[string]string, error) {
+	return c.GetPatternContext(context.Background(), pattern)
+}
+
+// GetPatternContext is like GetPattern, but it checks ctx between files.
+// If ctx is done before all sysctls are read, it stops and returns
+// a *CanceledError reporting how many sysctls were listed or read.
+func (c *Client) GetPatternContext(ctx context.Context, pattern string) (map[string]string, error) {
+	res := make(map[string]string)
+	err := c.WalkPatternContext(ctx, pattern, func(key, value string, err error) error {
+		// sysctls that cannot be read, e.g. for lack of
+		// permissions, are silently skipped
+		if err == nil {
+			res[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// WalkFunc is the type of the function called by WalkPattern for each
+// sysctl. If the sysctl could not be read, e.g. for lack of permissions,
+// value is empty and err is a *KeyError. If the function returns an error,
+// the walk stops and returns it.
+type WalkFunc func(key, value string, err error) error
+
+// WalkPattern calls fn for each sysctl matching a given pattern, as
+// matched by GetPattern, in the order in which the sysctls are listed.
+// Unlike GetPattern, sysctls that cannot be read are not skipped, but
+// passed to fn along with the error occurred reading them.
+func (c *Client) WalkPattern(pattern string, fn WalkFunc) error {
+	return c.WalkPatternContext(context.Background(), pattern, fn)
+}
+
+// WalkPatternContext is like WalkPattern, but it checks ctx between files,
+// as GetPatternContext does. Sysctls are read before calling fn, so that
+// fn is not called if ctx is done before all sysctls are read.
+func (c *Client) WalkPatternContext(ctx context.Context, pattern string, fn WalkFunc) error {
 	re, err := regexp.CompilePOSIX(pattern)
 	if err != nil {
-		return nil, fmt.Errorf("invalid pattern: %v", err)
+		return fmt.Errorf("invalid pattern: %w", err)
 	}
-	res := make(map[string]string)
-	err = filepath.Walk(c.path, func(path string, info os.FileInfo, err error) error {
+	var (
+		keys, vals []string
+		errs       []error
+	)
+	if c.backend != nil {
+		keys, vals, errs, err = c.readPatternBackend(ctx, re)
+	} else {
+		keys, vals, errs, err = c.readPatternFiles(ctx, pattern, re)
+	}
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if err := fn(key, vals[i], errs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPatternFiles reads the files of the sysctls matching a given
+// regular expression, compiled from pattern, returning their keys along
+// with their values or the errors occurred reading them
+func (c *Client) readPatternFiles(ctx context.Context, pattern string, re *regexp.Regexp) (keys, vals []string, errs []error, err error) {
+	// Only directories that may contain matching keys are
+	// visited and matching files are read concurrently
+	prefix := patternPrefix(pattern)
+	err = c.do(func() error {
+		root, err := os.OpenRoot(c.path)
 		if err != nil {
-			return fmt.Errorf("error accessing sysctl path: %v", err)
+			return err
 		}
-		if info.IsDir() {
-			return nil
+		defer root.Close()
+		var paths []string
+		keys, paths, err = walkKeys(ctx, root.FS(), prefix)
+		if err != nil {
+			return err
+		}
+		n := 0
+		for i, key := range keys {
+			if re.MatchString(key) {
+				keys[n], paths[n] = key, paths[i]
+				n++
+			}
+		}
+		keys = keys[:n]
+		vals, errs, err = readFiles(ctx, root, keys, paths[:n], c.readWorkers())
+		return err
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return keys, vals, errs, nil
+}
+
+// readPatternBackend reads the sysctls of the client backend matching
+// a given regular expression, returning their keys along with their
+// values or the errors occurred reading them
+func (c *Client) readPatternBackend(ctx context.Context, re *regexp.Regexp) (keys, vals []string, errs []error, err error) {
+	all, err := c.backend.Keys()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("error listing sysctls: %w", err)
+	}
+	for i, key := range all {
+		if err := ctx.Err(); err != nil {
+			return nil, nil, nil, &CanceledError{Op: "get", Done: i, Total: len(all), Err: err}
 		}
-		key := c.keyFromPath(path)
 		if !re.MatchString(key) {
-			return nil
+			continue
 		}
-		val, err := readFile(path)
+		val, err := c.backend.Read(key)
 		if err != nil {
-			var pathError *os.PathError
-			if errors.As(err, &pathError) {
-				switch pathError.Op {
-				case "open", "read":
-					// this occurs if the file is not readable,
-					// which should not be considered an error.
-					// Instead, we should silently skip sysctls
-					// we have no permissions to read.
-					return nil
-				default:
-					return fmt.Errorf("error reading %s: op: %s, err: %s", path, pathError.Op, pathError.Err)
-				}
-
+			err = &KeyError{Key: key, Op: "get", Err: err}
+			var pathError *fs.PathError
+			if !errors.Is(err, fs.ErrPermission) && !errors.Is(err, fs.ErrNotExist) &&
+				!(errors.As(err, &pathError) && pathError.Op == "read") {
+				return nil, nil, nil, err
 			}
-			return fmt.Errorf("error reading %s: %v", path, err)
+			// as for files, sysctls that cannot be read
+			// only affect their own key
 		}
-		res[key] = val
-		return nil
-	})
-	if err != nil {
-		return nil, err
+		keys = append(keys, key)
+		vals = append(vals, val)
+		errs = append(errs, err)
 	}
-	return res, nil
+	return keys, vals, errs, nil
 }
 
 // GetAll returns all sysctls. This is equivalent
@@ -123,23 +317,201 @@ func (c *Client) GetAll() (map[string]string, error) {
 	return c.GetPattern("")
 }
 
+// GetAllContext is like GetAll, but it checks ctx between files,
+// as GetPatternContext does.
+func (c *Client) GetAllContext(ctx context.Context) (map[string]string, error) {
+	return c.GetPatternContext(ctx, "")
+}
+
 // Set updates the value of a sysctl.
+// Errors are of type *KeyError.
 func (c *Client) Set(key, value string) error {
-	return writeFile(c.pathFromKey(key), value)
+	if err := c.write(key, value); err != nil {
+		return &KeyError{Key: key, Op: "set", Err: err}
+	}
+	return nil
+}
+
+// SetContext is like Set, but it fails without writing the sysctl
+// if ctx is done, with a *KeyError wrapping the error of ctx.
+func (c *Client) SetContext(ctx context.Context, key, value string) error {
+	if err := ctx.Err(); err != nil {
+		return &KeyError{Key: key, Op: "set", Err: err}
+	}
+	return c.Set(key, value)
+}
+
+// write sets the value of a sysctl in the client backend
+func (c *Client) write(key, value string) error {
+	if c.backend != nil {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		return c.backend.Write(CanonicalKey(key), value)
+	}
+	return c.do(func() error {
+		root, name, err := c.openKey(key)
+		if err != nil {
+			return err
+		}
+		defer root.Close()
+		return writeFile(root, name, value)
+	})
+}
+
+// SetVerified updates the value of a sysctl and reads it back to verify
+// that it was applied. This is useful because the kernel may silently
+// clamp or rewrite some values.
+// Values are compared regardless of differences in whitespace.
+// If the value read back differs, a *MismatchError is returned.
+func (c *Client) SetVerified(key, value string) error {
+	if err := c.Set(key, value); err != nil {
+		return err
+	}
+	return c.verify(key, value)
+}
+
+// verify checks that the current value of a sysctl matches a given value
+func (c *Client) verify(key, value string) error {
+	actual, err := c.Get(key)
+	if err != nil {
+		return err
+	}
+	if normalizeValue(actual) != normalizeValue(value) {
+		return &MismatchError{Key: key, Requested: value, Actual: actual}
+	}
+	return nil
 }
 
 // LoadConfigAndApply sets sysctl values from a list of sysctl configuration files.
 // The values in the rightmost files take priority.
 // If no file is specified, values are read from /etc/sysctl.conf.
+// See ApplyConfig for details on how values are applied.
+// To apply values with options, such as WithVerify, use
+// LoadConfigAndApplyWith instead.
 func (c *Client) LoadConfigAndApply(files ...string) error {
-	config, err := LoadConfig(files...)
+	return c.LoadConfigAndApplyWith(files)
+}
+
+// LoadConfigAndApplyWith is like LoadConfigAndApply, but values are
+// applied with the given options, as in ApplyConfig.
+func (c *Client) LoadConfigAndApplyWith(files []string, opts ...ApplyOption) error {
+	config, err := ReadConfig(files...)
 	if err != nil {
-		return fmt.Errorf("could not read configuration from files: %v", err)
+		return fmt.Errorf("could not read configuration from files: %w", err)
 	}
-	for k, v := range config {
-		if err := c.Set(k, v); err != nil {
-			return fmt.Errorf("could not set %s = %s: %v", k, v, err)
+	return c.ApplyConfig(config, opts...)
+}
+
+// LoadConfigAndApplyContext is like LoadConfigAndApply, but it checks ctx
+// between sysctls, as ApplyConfigContext does.
+// To apply values with options, use ReadConfig and ApplyConfigContext
+// instead.
+func (c *Client) LoadConfigAndApplyContext(ctx context.Context, files ...string) error {
+	config, err := ReadConfig(files...)
+	if err != nil {
+		return fmt.Errorf("could not read configuration from files: %w", err)
+	}
+	return c.ApplyConfigContext(ctx, config)
+}
+
+// LoadSystemConfigAndApply sets sysctl values from all system configuration
+// files, as returned by SystemConfigFiles.
+// This is equivalent to what systemd-sysctl does on boot.
+func (c *Client) LoadSystemConfigAndApply() error {
+	config, err := ReadSystemConfig()
+	if err != nil {
+		return fmt.Errorf("could not read system configuration: %w", err)
+	}
+	return c.ApplyConfig(config)
+}
+
+// ApplyConfig sets sysctl values from a configuration, in the order
+// returned by Config.Effective.
+// Failures to set keys prefixed with "-" in the configuration are ignored.
+// Keys containing glob patterns are expanded against the existing sysctls,
+// with explicit settings taking precedence over glob matches.
+func (c *Client) ApplyConfig(config *Config, opts ...ApplyOption) error {
+	return c.ApplyConfigContext(context.Background(), config, opts...)
+}
+
+// ApplyConfigContext is like ApplyConfig, but it checks ctx between
+// sysctls. If ctx is done before all values are set, it stops and
+// returns a *CanceledError reporting how many sysctls were set, after
+// restoring the sysctls already written if WithRollback is used.
+func (c *Client) ApplyConfigContext(ctx context.Context, config *Config, opts ...ApplyOption) error {
+	entries, err := c.resolve(ctx, config)
+	if err != nil {
+		return err
+	}
+	return c.apply(ctx, entries, opts)
+}
+
+// ResolveConfig returns the entries that ApplyConfig sets for a
+// configuration, in the order in which they are set, with keys containing
+// glob patterns expanded against the existing sysctls and exclusions removed.
+func (c *Client) ResolveConfig(config *Config) ([]Entry, error) {
+	return c.resolve(context.Background(), config)
+}
+
+// resolve returns the entries of a configuration that take effect,
+// with glob patterns expanded against the existing sysctls and exclusions
+// removed.
+func (c *Client) resolve(ctx context.Context, config *Config) ([]Entry, error) {
+	effective := config.Effective()
+	explicit := make(map[string]bool, len(effective))
+	for _, e := range effective {
+		explicit[e.Key] = true
+	}
+	var (
+		keys []string
+		out  []Entry
+	)
+	for _, e := range effective {
+		if e.Exclude {
+			continue
+		}
+		if !isGlob(e.Key) {
+			out = append(out, e)
+			continue
+		}
+		if keys == nil {
+			var err error
+			if keys, err = c.keys(ctx); err != nil {
+				return nil, fmt.Errorf("could not list sysctls: %w", err)
+			}
+		}
+		for _, key := range keys {
+			// explicit settings and exclusions take
+			// precedence over glob matches
+			if explicit[key] || !matchKey(e.Key, key) {
+				continue
+			}
+			m := e
+			m.Key = key
+			out = append(out, m)
 		}
 	}
-	return nil
+	return out, nil
+}
+
+// keys returns the keys of all sysctls
+func (c *Client) keys(ctx context.Context) ([]string, error) {
+	if c.backend != nil {
+		return c.backend.Keys()
+	}
+	var keys []string
+	err := c.do(func() error {
+		root, err := os.OpenRoot(c.path)
+		if err != nil {
+			return err
+		}
+		defer root.Close()
+		keys, _, err = walkKeys(ctx, root.FS(), "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
 }
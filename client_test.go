@@ -1,11 +1,14 @@
 package sysctl
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
 func TestNewClient(t *testing.T) {
@@ -60,52 +63,6 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
-func TestClient_pathFromKey(t *testing.T) {
-	cases := []struct {
-		base     string
-		in       string
-		expected string
-	}{
-		{
-			base:     "/a/b/",
-			in:       "net.ipv4.ip_forward",
-			expected: "/a/b/net/ipv4/ip_forward",
-		},
-	}
-	for _, c := range cases {
-		t.Run(c.in, func(t *testing.T) {
-			cl := Client{path: c.base}
-			got := cl.pathFromKey(c.in)
-			if got != c.expected {
-				t.Fatalf("expected: %s. Got: %s", c.expected, got)
-			}
-		})
-	}
-}
-
-func TestClient_keyFromPath(t *testing.T) {
-	cases := []struct {
-		base     string
-		in       string
-		expected string
-	}{
-		{
-			base:     "/a/b/",
-			in:       "/a/b/net/ipv4/ip_forward",
-			expected: "net.ipv4.ip_forward",
-		},
-	}
-	for _, c := range cases {
-		t.Run(c.in, func(t *testing.T) {
-			cl := Client{path: c.base}
-			got := cl.keyFromPath(c.in)
-			if got != c.expected {
-				t.Fatalf("expected: %s. Got: %s", c.expected, got)
-			}
-		})
-	}
-}
-
 func TestClientGet(t *testing.T) {
 	cases := []struct {
 		name string
@@ -299,6 +256,64 @@ func TestClientGetAll(t *testing.T) {
 	}
 }
 
+func TestClientWalkPattern(t *testing.T) {
+	cl := newTestClient(t, map[string]string{
+		"a":   "1",
+		"b/c": "2",
+	})
+	var (
+		keys []string
+		vals = make(map[string]string)
+		errs = make(map[string]error)
+	)
+	err := cl.WalkPattern("", func(key, value string, err error) error {
+		keys = append(keys, key)
+		if err != nil {
+			errs[key] = err
+			return nil
+		}
+		vals[key] = value
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not walk sysctls: %v", err)
+	}
+	if diff := cmp.Diff([]string{"a", "b.c", "null", "ro", "wo"}, keys); diff != "" {
+		t.Fatalf("unexpected keys (-want +got):\n%s", diff)
+	}
+	expected := map[string]string{
+		"a":    "1",
+		"b.c":  "2",
+		"null": "",
+		"ro":   "6.1.0",
+	}
+	if diff := cmp.Diff(expected, vals); diff != "" {
+		t.Fatalf("unexpected values (-want +got):\n%s", diff)
+	}
+	// write-only sysctls are reported instead of being skipped
+	var kerr *KeyError
+	if len(errs) != 1 || !errors.As(errs["wo"], &kerr) || !errors.Is(errs["wo"], ErrPermission) {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	got, err := cl.GetAll()
+	if err != nil {
+		t.Fatalf("could not get values: %v", err)
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected values (-want +got):\n%s", diff)
+	}
+
+	errStop := errors.New("stop")
+	n := 0
+	err = cl.WalkPattern("^b", func(key, value string, err error) error {
+		n++
+		return errStop
+	})
+	if !errors.Is(err, errStop) || n != 1 {
+		t.Fatalf("walk not stopped: %d calls, err: %v", n, err)
+	}
+}
+
 func TestClientSet(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -324,6 +339,13 @@ func TestClientSet(t *testing.T) {
 			},
 			ok: true,
 		},
+		{
+			name:  "missing",
+			files: []string{"a"},
+			keys: map[string]string{
+				"b": "value of b",
+			},
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -403,6 +425,38 @@ func TestClientLoadConfigAndApply(t *testing.T) {
 				"b/b/a",
 			},
 		},
+		{
+			name:   "glob",
+			config: "testdata/client/config-glob.conf",
+			files: []string{
+				"b/x/a",
+				"b/y/a",
+				"b/z/a",
+				"b/z/b",
+			},
+			expected: map[string]string{
+				"b.x.a": "value of glob",
+				"b.y.a": "value of b.y.a",
+				"b.z.a": "",
+				"b.z.b": "",
+			},
+			ok: true,
+		},
+		{
+			name:   "missing keys ignored",
+			config: "testdata/client/config-missing-keys-ignored.conf",
+			files: []string{
+				"a",
+				"b/a",
+				"b/b/a",
+			},
+			expected: map[string]string{
+				"a":     "value of a",
+				"b.a":   "value of b.a",
+				"b.b.a": "value of b.b.a",
+			},
+			ok: true,
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
@@ -456,3 +510,206 @@ func createTestFiles(t *testing.T, base string, paths []string) {
 		_ = f.Close()
 	}
 }
+
+func TestClientSetVerified(t *testing.T) {
+	cl := newTestClient(t, map[string]string{
+		"a": "1",
+	})
+	if err := cl.SetVerified("a", "4096\t131072"); err != nil {
+		t.Fatalf("could not set a: %v", err)
+	}
+	// writes to null succeed but reading it back returns an empty value
+	err := cl.SetVerified("null", "1")
+	if err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+	t.Logf("err: %v", err)
+	var merr *MismatchError
+	if !errors.As(err, &merr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	expected := MismatchError{Key: "null", Requested: "1", Actual: ""}
+	if *merr != expected {
+		t.Fatalf("unexpected error: %+v", merr)
+	}
+}
+
+func TestClientDottedKeys(t *testing.T) {
+	path := t.TempDir()
+	createConfigFiles(t, path, map[string]string{
+		"net/ipv4/conf/eth0.100/rp_filter": "1",
+		"net/ipv4/conf/eth0/rp_filter":     "0",
+	})
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	all, err := cl.GetAll()
+	if err != nil {
+		t.Fatalf("could not get all sysctls: %v", err)
+	}
+	expected := map[string]string{
+		"net.ipv4.conf.eth0/100.rp_filter": "1",
+		"net.ipv4.conf.eth0.rp_filter":     "0",
+	}
+	if diff := cmp.Diff(expected, all); diff != "" {
+		t.Fatalf("unexpected output (-want +got):\n%s", diff)
+	}
+	for k, v := range all {
+		got, err := cl.Get(k)
+		if err != nil {
+			t.Fatalf("could not get key %s: %v", k, err)
+		}
+		if got != v {
+			t.Fatalf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+		}
+	}
+	if err := cl.Set("net/ipv4/conf/eth0.100/rp_filter", "2"); err != nil {
+		t.Fatalf("could not set key: %v", err)
+	}
+	got, err := cl.Get("net.ipv4.conf.eth0/100.rp_filter")
+	if err != nil {
+		t.Fatalf("could not get key: %v", err)
+	}
+	if got != "2" {
+		t.Fatalf("got wrong value: expected: 2, got %s", got)
+	}
+}
+
+func TestClientConfinedToRoot(t *testing.T) {
+	base := t.TempDir()
+	createConfigFiles(t, base, map[string]string{
+		"secret":   "secret value",
+		"root/a/f": "value of a.f",
+	})
+	path := filepath.Join(base, "root")
+	if err := os.Symlink(filepath.Join(base, "secret"), filepath.Join(path, "a", "link")); err != nil {
+		t.Fatalf("could not create symlink: %v", err)
+	}
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	for _, key := range []string{"..secret", "../secret", "a.link"} {
+		if _, err := cl.Get(key); err == nil {
+			t.Fatalf("expected error getting %s but it succeeded", key)
+		} else {
+			t.Logf("err: %v", err)
+		}
+		if err := cl.Set(key, "x"); err == nil {
+			t.Fatalf("expected error setting %s but it succeeded", key)
+		}
+	}
+	if _, err := cl.Get("..secret"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected invalid key error, got: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(base, "secret"))
+	if err != nil {
+		t.Fatalf("could not read file: %v", err)
+	}
+	if string(got) != "secret value" {
+		t.Fatalf("file outside of root was modified: %s", got)
+	}
+}
+
+// countdownContext is a context canceled after its Err method
+// is called a given number of times
+type countdownContext struct {
+	context.Context
+	n int
+}
+
+func (c *countdownContext) Err() error {
+	if c.n <= 0 {
+		return context.Canceled
+	}
+	c.n--
+	return nil
+}
+
+func TestClientContext(t *testing.T) {
+	cases := []struct {
+		name  string
+		calls int
+		err   *CanceledError
+	}{
+		{
+			name: "canceled",
+			err:  &CanceledError{Op: "list", Done: 0, Total: -1, Err: context.Canceled},
+		},
+		{
+			// the walk checks the context once for each of
+			// the 7 entries of the tree, including the root
+			name:  "walk",
+			calls: 5,
+			err:   &CanceledError{Op: "list", Done: 2, Total: -1, Err: context.Canceled},
+		},
+		{
+			name:  "read",
+			calls: 9,
+			err:   &CanceledError{Op: "get", Done: 2, Total: 4, Err: context.Canceled},
+		},
+		{
+			name:  "not canceled",
+			calls: 11,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl, err := NewClient("testdata/client/ok")
+			if err != nil {
+				t.Fatalf("could not create client: %v", err)
+			}
+			cl.workers = 1
+			ctx := &countdownContext{Context: context.Background(), n: c.calls}
+			res, err := cl.GetAllContext(ctx)
+			if c.err == nil {
+				if err != nil {
+					t.Fatalf("could not get values: %v", err)
+				}
+				if len(res) != 4 {
+					t.Fatalf("unexpected number of values: %d", len(res))
+				}
+				return
+			}
+			if !errors.Is(err, context.Canceled) {
+				t.Fatalf("expected error matching context.Canceled, got: %v", err)
+			}
+			var cerr *CanceledError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("expected *CanceledError, got: %T", err)
+			}
+			if diff := cmp.Diff(*c.err, *cerr, cmpopts.EquateErrors()); diff != "" {
+				t.Fatalf("unexpected error (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientGetSetContext(t *testing.T) {
+	path := t.TempDir()
+	createTestFiles(t, path, []string{"a"})
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := cl.SetContext(ctx, "a", "1"); err != nil {
+		t.Fatalf("could not set value: %v", err)
+	}
+	if v, err := cl.GetContext(ctx, "a"); err != nil || v != "1" {
+		t.Fatalf("unexpected value: %q, err: %v", v, err)
+	}
+	cancel()
+	err = cl.SetContext(ctx, "a", "2")
+	var kerr *KeyError
+	if !errors.As(err, &kerr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected *KeyError matching context.Canceled, got: %v", err)
+	}
+	if _, err := cl.GetContext(ctx, "a"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected error matching context.Canceled, got: %v", err)
+	}
+	if v, err := cl.Get("a"); err != nil || v != "1" {
+		t.Fatalf("unexpected value: %q, err: %v", v, err)
+	}
+}
@@ -0,0 +1,57 @@
+//go:build linux
+// +build linux
+
+// Command oci-sysctl-hook is an OCI runtime hook setting the sysctls
+// listed under linux.sysctl in the configuration of a container.
+//
+// It is meant to be run as a createRuntime or prestart hook, which
+// receive the state of the container on standard input. The sysctls are
+// read from the config.json file of the container bundle, validated and
+// set in the namespaces of the container process.
+//
+// Usage:
+//
+//	oci-sysctl-hook [-rollback]
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+	"github.com/lorenzosaino/go-sysctl/oci"
+)
+
+func main() {
+	rollback := flag.Bool("rollback", false, "restore all sysctls already set if setting any sysctl fails")
+	flag.Parse()
+	if err := run(os.Stdin, *rollback); err != nil {
+		fmt.Fprintf(os.Stderr, "oci-sysctl-hook: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(stdin io.Reader, rollback bool) error {
+	state, err := oci.ReadState(stdin)
+	if err != nil {
+		return err
+	}
+	if state.Pid <= 0 {
+		return fmt.Errorf("container %s has no process", state.ID)
+	}
+	spec, err := oci.ReadSpec(filepath.Join(state.Bundle, "config.json"))
+	if err != nil {
+		return err
+	}
+	var opts []sysctl.ApplyOption
+	if rollback {
+		opts = append(opts, sysctl.WithRollback())
+	}
+	if err := oci.Apply(spec, state.Pid, opts...); err != nil {
+		return fmt.Errorf("could not set sysctls of container %s: %w", state.ID, err)
+	}
+	return nil
+}
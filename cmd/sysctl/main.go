@@ -0,0 +1,568 @@
+// Command sysctl reads and writes sysctls at runtime.
+//
+// It is a replacement for the sysctl command of procps-ng, accepting the
+// same options and producing the same output and exit codes, for
+// environments where procps is not available, such as minimal container
+// images.
+//
+// Usage:
+//
+//	sysctl [options] [variable[=value] ...]
+//
+// Run sysctl --help for the list of supported options.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+const usage = `
+Usage:
+ sysctl [options] [variable[=value] ...]
+
+Options:
+  -a, --all            display all variables
+  -A                   alias of -a
+  -X                   alias of -a
+      --deprecated     include deprecated parameters to listing
+      --dry-run        Print the key and values but do not write
+  -b, --binary         print value without new line
+  -e, --ignore         ignore unknown variables errors
+  -N, --names          print variable names without values
+  -n, --values         print only values of the given variable(s)
+  -p, --load[=<file>]  read values from file
+  -f                   alias of -p
+      --system         read values from all system directories
+  -r, --pattern <expression>
+                       select setting that match expression
+  -q, --quiet          do not echo variable set
+  -w, --write          enable writing a value to variable
+  -o                   does nothing
+  -x                   does nothing
+  -d                   alias of -h
+
+ -h, --help     display this help and exit
+ -V, --version  output version information and exit
+
+For more details see sysctl(8).
+`
+
+const version = "sysctl from go-sysctl\n"
+
+// Exit codes, as returned by procps-ng sysctl
+const (
+	exitOK      = 0
+	exitFailure = 1
+)
+
+// deprecated are the names of the files of deprecated sysctls,
+// which are not listed unless requested
+var deprecated = map[string]bool{
+	"base_reachable_time": true,
+	"retrans_time":        true,
+}
+
+var (
+	// defaultConfigFile is the file loaded by -p if no file is specified
+	// and the last file loaded by --system
+	defaultConfigFile = "/etc/sysctl.conf"
+	// systemConfigFiles returns the files loaded by --system
+	systemConfigFiles = sysctl.SystemConfigFiles
+	// newClient returns the client used to read and write the sysctls
+	// in a path
+	newClient = sysctl.NewClient
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr, sysctl.DefaultPath))
+}
+
+// options are the parsed command line arguments
+type options struct {
+	all        bool
+	deprecated bool
+	dryRun     bool
+	binary     bool
+	values     bool
+	names      bool
+	ignore     bool
+	quiet      bool
+	write      bool
+	load       bool
+	system     bool
+	help       bool
+	version    bool
+	pattern    string
+	// files are the files passed to -p or --load
+	files []string
+	// args are the positional arguments
+	args []string
+}
+
+// parseArgs parses command line arguments. As in procps, short options
+// can be combined, as in -qw, and -p accepts an optional file only if
+// attached to it, as in -pfile.
+func parseArgs(args []string) (*options, error) {
+	o := &options{}
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		switch {
+		case a == "--":
+			o.args = append(o.args, args[i+1:]...)
+			return o, nil
+		case strings.HasPrefix(a, "--"):
+			name, val, hasVal := strings.Cut(a[2:], "=")
+			if hasVal && name != "load" && name != "pattern" {
+				return nil, fmt.Errorf("option '--%s' doesn't allow an argument", name)
+			}
+			switch name {
+			case "all":
+				o.all = true
+			case "deprecated":
+				o.deprecated = true
+			case "dry-run":
+				o.dryRun = true
+			case "binary":
+				o.binary = true
+			case "values":
+				o.values = true
+			case "names":
+				o.names = true
+			case "ignore":
+				o.ignore = true
+			case "quiet":
+				o.quiet = true
+			case "write":
+				o.write = true
+			case "system":
+				o.system = true
+			case "help":
+				o.help = true
+			case "version":
+				o.version = true
+			case "load":
+				o.load = true
+				if hasVal {
+					o.files = append(o.files, val)
+				}
+			case "pattern":
+				if !hasVal {
+					if i++; i == len(args) {
+						return nil, fmt.Errorf("option '--pattern' requires an argument")
+					}
+					val = args[i]
+				}
+				o.pattern = val
+			default:
+				return nil, fmt.Errorf("unrecognized option '%s'", a)
+			}
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			for j := 1; j < len(a); j++ {
+				switch a[j] {
+				case 'a', 'A', 'X':
+					o.all = true
+				case 'b':
+					o.binary = true
+				case 'n':
+					o.values = true
+				case 'N':
+					o.names = true
+				case 'e':
+					o.ignore = true
+				case 'q':
+					o.quiet = true
+				case 'w':
+					o.write = true
+				case 'o', 'x':
+				case 'h', 'd':
+					o.help = true
+				case 'V':
+					o.version = true
+				case 'p', 'f':
+					o.load = true
+					if rest := a[j+1:]; rest != "" {
+						o.files = append(o.files, rest)
+						j = len(a)
+					}
+				case 'r':
+					if rest := a[j+1:]; rest != "" {
+						o.pattern = rest
+						j = len(a)
+						break
+					}
+					if i++; i == len(args) {
+						return nil, fmt.Errorf("option requires an argument -- 'r'")
+					}
+					o.pattern = args[i]
+				default:
+					return nil, fmt.Errorf("invalid option -- '%c'", a[j])
+				}
+			}
+		default:
+			o.args = append(o.args, a)
+		}
+	}
+	return o, nil
+}
+
+// command runs a sysctl command
+type command struct {
+	opts *options
+	cl   *sysctl.Client
+	// root is the directory of the sysctls, used to check them
+	// before setting them
+	root    *os.Root
+	path    string
+	pattern *regexp.Regexp
+	stdin   io.Reader
+	stdout  io.Writer
+	stderr  io.Writer
+	// failed is set if any operation failed
+	failed bool
+}
+
+// run runs the command with the given arguments on the sysctls in path
+// and returns its exit code
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer, path string) int {
+	o, err := parseArgs(args)
+	if err != nil {
+		fmt.Fprintf(stderr, "sysctl: %v\n%s", err, usage)
+		return exitFailure
+	}
+	switch {
+	case o.help:
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	case o.version:
+		fmt.Fprint(stdout, version)
+		return exitOK
+	}
+	c := &command{opts: o, path: path, stdin: stdin, stdout: stdout, stderr: stderr}
+	if o.pattern != "" {
+		if c.pattern, err = regexp.CompilePOSIX(o.pattern); err != nil {
+			fmt.Fprintf(stderr, "sysctl: unable to parse pattern: %v\n", err)
+			return exitFailure
+		}
+	}
+	if c.cl, err = newClient(path); err != nil {
+		fmt.Fprintf(stderr, "sysctl: %v\n", err)
+		return exitFailure
+	}
+	if c.root, err = os.OpenRoot(path); err != nil {
+		fmt.Fprintf(stderr, "sysctl: %v\n", err)
+		return exitFailure
+	}
+	defer c.root.Close()
+	switch {
+	case o.system:
+		c.system()
+	case o.load:
+		files := append(o.files, o.args...)
+		if len(files) == 0 {
+			files = []string{defaultConfigFile}
+		}
+		for _, f := range files {
+			c.load(f)
+		}
+	case o.all:
+		c.displayAll("")
+	case len(o.args) == 0:
+		fmt.Fprint(stderr, usage)
+		return exitFailure
+	default:
+		for _, a := range o.args {
+			if o.write || strings.Contains(a, "=") {
+				c.write(a)
+			} else {
+				c.read(sysctl.CanonicalKey(a))
+			}
+		}
+	}
+	if c.failed {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// system loads all system configuration files
+func (c *command) system() {
+	files, err := systemConfigFiles()
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	if _, err := os.Stat(defaultConfigFile); err == nil {
+		files = append(files, defaultConfigFile)
+	}
+	for _, f := range files {
+		if !c.opts.quiet {
+			fmt.Fprintf(c.stdout, "* Applying %s ...\n", f)
+		}
+		c.load(f)
+	}
+}
+
+// load sets the sysctls in a configuration file.
+// As in procps, invalid lines are reported and skipped.
+func (c *command) load(file string) {
+	var (
+		data []byte
+		err  error
+	)
+	if file == "-" {
+		data, err = io.ReadAll(c.stdin)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		c.errorf("cannot open %q: %s", file, strerror(err))
+		return
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	var config *sysctl.Config
+	for {
+		config, err = sysctl.ParseConfig(bytes.NewReader(bytes.Join(lines, []byte("\n"))), file)
+		var perr *sysctl.ParseError
+		if !errors.As(err, &perr) || perr.Line < 1 || perr.Line > len(lines) {
+			break
+		}
+		c.errorf("%s(%d): invalid syntax, continuing...", file, perr.Line)
+		// blank the invalid line, so that line numbers are preserved
+		lines[perr.Line-1] = nil
+	}
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	entries, err := c.cl.ResolveConfig(config)
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	for _, e := range entries {
+		if c.pattern != nil && !c.pattern.MatchString(e.Key) {
+			continue
+		}
+		c.set(e.Key, e.Value, e.IgnoreFailure)
+	}
+}
+
+// displayAll prints all sysctls whose key starts with a given prefix.
+// As in procps, sysctls that cannot be read are reported, unless only
+// names are printed, but they do not make the command fail.
+func (c *command) displayAll(prefix string) {
+	pattern := c.opts.pattern
+	if prefix != "" {
+		pattern = "^" + regexp.QuoteMeta(prefix)
+	}
+	var keys []string
+	values := make(map[string]string)
+	errs := make(map[string]error)
+	err := c.cl.WalkPattern(pattern, func(key, value string, err error) error {
+		if c.pattern != nil && !c.pattern.MatchString(key) {
+			return nil
+		}
+		p := keyPath(key)
+		if !c.opts.deprecated && deprecated[p[strings.LastIndex(p, "/")+1:]] {
+			return nil
+		}
+		keys = append(keys, key)
+		if err != nil {
+			errs[key] = err
+			return nil
+		}
+		values[key] = value
+		return nil
+	})
+	if err != nil {
+		c.errorf("%v", err)
+		return
+	}
+	sortKeys(keys)
+	for _, k := range keys {
+		err, ok := errs[k]
+		switch {
+		case !ok || c.opts.names:
+			c.printValue(k, values[k])
+		case c.opts.ignore:
+		case errors.Is(err, sysctl.ErrPermission):
+			fmt.Fprintf(c.stderr, "sysctl: permission denied on key '%s'\n", k)
+		default:
+			fmt.Fprintf(c.stderr, "sysctl: reading key %q: %s\n", k, strerror(err))
+		}
+	}
+}
+
+// read prints a sysctl or, if the key refers to a directory,
+// all sysctls in it
+func (c *command) read(key string) {
+	val, err := c.cl.Get(key)
+	if errors.Is(err, syscall.EISDIR) {
+		c.displayAll(key + ".")
+		return
+	}
+	if errors.Is(err, sysctl.ErrNotExist) {
+		if !c.opts.ignore {
+			c.errorf("cannot stat %s%s: No such file or directory", c.path, keyPath(key))
+		}
+		return
+	}
+	if c.pattern != nil && !c.pattern.MatchString(key) {
+		return
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, sysctl.ErrPermission):
+			c.errorf("permission denied on key '%s'", key)
+		default:
+			c.errorf("reading key %q: %s", key, strerror(err))
+		}
+		return
+	}
+	c.printValue(key, val)
+}
+
+// write sets a sysctl from a setting in the form key=value
+func (c *command) write(setting string) {
+	key, val, ok := strings.Cut(setting, "=")
+	key = sysctl.CanonicalKey(strings.TrimSpace(key))
+	if !ok || key == "" {
+		c.errorf("command line(0): invalid syntax, continuing...")
+		return
+	}
+	c.set(key, strings.TrimSpace(val), false)
+}
+
+// set sets a sysctl and prints it.
+// As in procps, ignoring failures only applies to permission errors,
+// in which case the sysctl is printed as if it was set.
+func (c *command) set(key, val string, ignoreFailure bool) {
+	// as in procps, sysctls are checked before being set, even in
+	// dry runs, and sysctls without write permission for the owner
+	// are not set, even by root
+	info, err := c.root.Stat(keyPath(key))
+	switch {
+	case err != nil:
+		if !c.opts.ignore {
+			c.errorf("cannot stat %s%s: %s", c.path, keyPath(key), strerror(err))
+		}
+		return
+	case info.IsDir() || info.Mode().Perm()&0o200 == 0:
+		fmt.Fprintf(c.stderr, "sysctl: setting key %q: %s\n", key, strerror(syscall.EINVAL))
+		return
+	}
+	if !c.opts.dryRun {
+		if err := c.cl.Set(key, val); err != nil {
+			switch {
+			case errors.Is(err, sysctl.ErrNotExist):
+				if !c.opts.ignore {
+					c.errorf("cannot stat %s%s: No such file or directory", c.path, keyPath(key))
+				}
+				return
+			case errors.Is(err, sysctl.ErrPermission) && ignoreFailure:
+				fmt.Fprintf(c.stderr, "sysctl: permission denied on key %q, ignoring\n", key)
+			case errors.Is(err, sysctl.ErrPermission):
+				c.errorf("permission denied on key %q", key)
+				return
+			default:
+				// procps does not fail if the kernel rejects the value,
+				// since the error is only reported when closing the file
+				fmt.Fprintf(c.stderr, "sysctl: setting key %q: %s\n", key, strerror(err))
+				return
+			}
+		}
+	}
+	if c.opts.quiet {
+		return
+	}
+	switch {
+	case c.opts.names:
+		fmt.Fprintln(c.stdout, key)
+	case c.opts.binary:
+		fmt.Fprint(c.stdout, val)
+	case c.opts.values:
+		fmt.Fprintln(c.stdout, val)
+	default:
+		fmt.Fprintf(c.stdout, "%s = %s\n", key, val)
+	}
+}
+
+// printValue prints the value of a sysctl, with one line for each line
+// of the value. As in procps, nothing is printed for empty values,
+// unless only names are printed.
+func (c *command) printValue(key, val string) {
+	switch {
+	case c.opts.names:
+		fmt.Fprintln(c.stdout, key)
+		return
+	case val == "":
+		return
+	case c.opts.binary:
+		fmt.Fprint(c.stdout, val)
+		return
+	}
+	for _, line := range strings.Split(val, "\n") {
+		if c.opts.values {
+			fmt.Fprintln(c.stdout, line)
+		} else {
+			fmt.Fprintf(c.stdout, "%s = %s\n", key, line)
+		}
+	}
+}
+
+func (c *command) errorf(format string, args ...interface{}) {
+	c.failed = true
+	fmt.Fprintf(c.stderr, "sysctl: "+format+"\n", args...)
+}
+
+// strerror returns the description of an error, formatted as by the
+// C library if it is a system error
+func strerror(err error) string {
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return err.Error()
+	}
+	s := errno.Error()
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// keyPath returns the path, relative to the sysctl root, of the file
+// of a key in canonical form
+func keyPath(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return '/'
+		case '/':
+			return '.'
+		}
+		return r
+	}, key)
+}
+
+// sortKeys sorts keys by comparing the names of their files one path
+// component at a time, which is the order in which procps lists sysctls
+func sortKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := strings.Split(keyPath(keys[i]), "/"), strings.Split(keyPath(keys[j]), "/")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+}
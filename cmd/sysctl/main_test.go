@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+	"github.com/lorenzosaino/go-sysctl/sysctltest"
+)
+
+func TestRun(t *testing.T) {
+	sysctls := map[string]string{
+		"net/ipv4/ip_forward":              "0",
+		"net/ipv4/conf/eth0.100/rp_filter": "2",
+		"net/ipv4/conf/eth0-1/rp_filter":   "1",
+		"net/ipv4/neigh/lo/retrans_time":   "100",
+		"kernel/hostname":                  "example",
+		"kernel/domainname":                "",
+		"kernel/ostype":                    "Linux",
+		"dev/cdrom/info":                   "drive name:\ndrive speed:",
+	}
+	cases := []struct {
+		name    string
+		args    []string
+		configs map[string]string
+		// unreadable are the keys of sysctls that cannot be read,
+		// which are served from memory since root can read any file
+		unreadable []string
+		stdin      string
+		stdout     string
+		stderr     string
+		code       int
+		expected   map[string]string
+	}{
+		{
+			name:   "read",
+			args:   []string{"net.ipv4.ip_forward", "kernel/hostname"},
+			stdout: "net.ipv4.ip_forward = 0\nkernel.hostname = example\n",
+		},
+		{
+			name:   "read values",
+			args:   []string{"-n", "net.ipv4.ip_forward"},
+			stdout: "0\n",
+		},
+		{
+			name:   "read names",
+			args:   []string{"-N", "net.ipv4.ip_forward"},
+			stdout: "net.ipv4.ip_forward\n",
+		},
+		{
+			name:   "read multi-line value",
+			args:   []string{"dev.cdrom.info"},
+			stdout: "dev.cdrom.info = drive name:\ndev.cdrom.info = drive speed:\n",
+		},
+		{
+			name:   "read missing",
+			args:   []string{"net.ipv4.missing", "net.ipv4.ip_forward"},
+			stdout: "net.ipv4.ip_forward = 0\n",
+			stderr: "sysctl: cannot stat {root}net/ipv4/missing: No such file or directory\n",
+			code:   1,
+		},
+		{
+			name:   "read missing ignored",
+			args:   []string{"-e", "net.ipv4.missing"},
+			stdout: "",
+		},
+		{
+			name:   "read binary",
+			args:   []string{"-b", "net.ipv4.ip_forward", "kernel.hostname"},
+			stdout: "0example",
+		},
+		{
+			name: "read empty value",
+			args: []string{"kernel.domainname"},
+		},
+		{
+			name: "read empty value values",
+			args: []string{"-n", "kernel.domainname"},
+		},
+		{
+			name:   "read empty value names",
+			args:   []string{"-N", "kernel.domainname"},
+			stdout: "kernel.domainname\n",
+		},
+		{
+			name: "read directory",
+			args: []string{"net.ipv4.conf"},
+			stdout: "net.ipv4.conf.eth0-1.rp_filter = 1\n" +
+				"net.ipv4.conf.eth0/100.rp_filter = 2\n",
+		},
+		{
+			name:   "read with pattern",
+			args:   []string{"-r", "^net", "net.ipv4.ip_forward", "kernel.hostname"},
+			stdout: "net.ipv4.ip_forward = 0\n",
+		},
+		{
+			name: "all",
+			args: []string{"-a"},
+			stdout: "dev.cdrom.info = drive name:\n" +
+				"dev.cdrom.info = drive speed:\n" +
+				"kernel.hostname = example\n" +
+				"kernel.ostype = Linux\n" +
+				"net.ipv4.conf.eth0-1.rp_filter = 1\n" +
+				"net.ipv4.conf.eth0/100.rp_filter = 2\n" +
+				"net.ipv4.ip_forward = 0\n",
+		},
+		{
+			name:   "all values",
+			args:   []string{"-an", "-r", "^kernel"},
+			stdout: "example\nLinux\n",
+		},
+		{
+			name:   "all names",
+			args:   []string{"-aN", "-r", "^kernel"},
+			stdout: "kernel.domainname\nkernel.hostname\nkernel.ostype\n",
+		},
+		{
+			name:       "all unreadable",
+			args:       []string{"-a", "-r", "^kernel"},
+			unreadable: []string{"kernel.hostname"},
+			stdout:     "kernel.ostype = Linux\n",
+			stderr:     "sysctl: permission denied on key 'kernel.hostname'\n",
+		},
+		{
+			name:       "all unreadable ignored",
+			args:       []string{"-ae", "-r", "^kernel"},
+			unreadable: []string{"kernel.hostname"},
+			stdout:     "kernel.ostype = Linux\n",
+		},
+		{
+			name:       "all unreadable names",
+			args:       []string{"-aN", "-r", "^kernel"},
+			unreadable: []string{"kernel.hostname"},
+			stdout:     "kernel.domainname\nkernel.hostname\nkernel.ostype\n",
+		},
+		{
+			name: "all deprecated",
+			args: []string{"-aN", "--deprecated", "-r", "ipv4"},
+			stdout: "net.ipv4.conf.eth0-1.rp_filter\n" +
+				"net.ipv4.conf.eth0/100.rp_filter\n" +
+				"net.ipv4.ip_forward\n" +
+				"net.ipv4.neigh.lo.retrans_time\n",
+		},
+		{
+			name:   "all with pattern",
+			args:   []string{"-aN", "--pattern", "rp_filter$"},
+			stdout: "net.ipv4.conf.eth0-1.rp_filter\nnet.ipv4.conf.eth0/100.rp_filter\n",
+		},
+		{
+			name:     "write",
+			args:     []string{"-w", "net.ipv4.ip_forward=1", "kernel.hostname = test"},
+			stdout:   "net.ipv4.ip_forward = 1\nkernel.hostname = test\n",
+			expected: map[string]string{"net.ipv4.ip_forward": "1", "kernel.hostname": "test"},
+		},
+		{
+			name:     "write dry run",
+			args:     []string{"--dry-run", "-n", "net.ipv4.ip_forward=1"},
+			stdout:   "1\n",
+			expected: map[string]string{"net.ipv4.ip_forward": "0"},
+		},
+		{
+			name:     "write dry run read-only",
+			args:     []string{"--dry-run", "kernel.ostype=x"},
+			stderr:   "sysctl: setting key \"kernel.ostype\": Invalid argument\n",
+			expected: map[string]string{"kernel.ostype": "Linux"},
+		},
+		{
+			name:   "write dry run missing",
+			args:   []string{"--dry-run", "net.ipv4.missing.key=1"},
+			stderr: "sysctl: cannot stat {root}net/ipv4/missing/key: No such file or directory\n",
+			code:   1,
+		},
+		{
+			name: "write dry run missing ignored",
+			args: []string{"-e", "--dry-run", "net.ipv4.missing.key=1"},
+		},
+		{
+			name:     "write empty value",
+			args:     []string{"kernel.domainname="},
+			stdout:   "kernel.domainname = \n",
+			expected: map[string]string{"kernel.domainname": ""},
+		},
+		{
+			name:     "write without -w",
+			args:     []string{"-q", "net/ipv4/ip_forward=1"},
+			expected: map[string]string{"net.ipv4.ip_forward": "1"},
+		},
+		{
+			name:   "write malformed",
+			args:   []string{"-w", "net.ipv4.ip_forward"},
+			stderr: "sysctl: command line(0): invalid syntax, continuing...\n",
+			code:   1,
+		},
+		{
+			name:   "write missing",
+			args:   []string{"net.ipv4.missing.key=1"},
+			stderr: "sysctl: cannot stat {root}net/ipv4/missing/key: No such file or directory\n",
+			code:   1,
+		},
+		{
+			name:     "write read-only",
+			args:     []string{"kernel.ostype=x", "net.ipv4.ip_forward=1"},
+			stdout:   "net.ipv4.ip_forward = 1\n",
+			stderr:   "sysctl: setting key \"kernel.ostype\": Invalid argument\n",
+			expected: map[string]string{"kernel.ostype": "Linux", "net.ipv4.ip_forward": "1"},
+		},
+		{
+			name: "load",
+			args: []string{"-p", "{conf}a.conf", "{conf}b.conf"},
+			configs: map[string]string{
+				"a.conf": "net.ipv4.ip_forward = 1\n",
+				"b.conf": "net.ipv4.conf.*.rp_filter = 0\n",
+			},
+			stdout: "net.ipv4.ip_forward = 1\n" +
+				"net.ipv4.conf.eth0-1.rp_filter = 0\n" +
+				"net.ipv4.conf.eth0/100.rp_filter = 0\n",
+			expected: map[string]string{
+				"net.ipv4.ip_forward":              "1",
+				"net.ipv4.conf.eth0/100.rp_filter": "0",
+				"net.ipv4.conf.eth0-1.rp_filter":   "0",
+			},
+		},
+		{
+			name: "load missing key with ignore failure",
+			args: []string{"-p", "{conf}a.conf"},
+			configs: map[string]string{
+				"a.conf": "-net.ipv4.missing.key = 1\n",
+			},
+			stderr: "sysctl: cannot stat {root}net/ipv4/missing/key: No such file or directory\n",
+			code:   1,
+		},
+		{
+			name: "load read-only",
+			args: []string{"-p", "{conf}a.conf"},
+			configs: map[string]string{
+				"a.conf": "kernel.ostype = x\nnet.ipv4.ip_forward = 1\n",
+			},
+			stdout:   "net.ipv4.ip_forward = 1\n",
+			stderr:   "sysctl: setting key \"kernel.ostype\": Invalid argument\n",
+			expected: map[string]string{"kernel.ostype": "Linux", "net.ipv4.ip_forward": "1"},
+		},
+		{
+			name: "load attached file",
+			args: []string{"-qp{conf}a.conf"},
+			configs: map[string]string{
+				"a.conf": "net.ipv4.ip_forward = 1\nnet.ipv4.missing.key = 1\n",
+			},
+			stderr:   "sysctl: cannot stat {root}net/ipv4/missing/key: No such file or directory\n",
+			code:     1,
+			expected: map[string]string{"net.ipv4.ip_forward": "1"},
+		},
+		{
+			name: "load default file",
+			args: []string{"--load"},
+			configs: map[string]string{
+				"sysctl.conf": "kernel.hostname = test\n",
+			},
+			stdout:   "kernel.hostname = test\n",
+			expected: map[string]string{"kernel.hostname": "test"},
+		},
+		{
+			name: "load invalid lines",
+			args: []string{"-p", "{conf}a.conf"},
+			configs: map[string]string{
+				"a.conf": "kernel.ostype\n kernel.hostname = test\nfoo\n",
+			},
+			stdout:   "kernel.hostname = test\n",
+			stderr:   "sysctl: {conf}a.conf(1): invalid syntax, continuing...\nsysctl: {conf}a.conf(3): invalid syntax, continuing...\n",
+			code:     1,
+			expected: map[string]string{"kernel.hostname": "test"},
+		},
+		{
+			name:     "load stdin",
+			args:     []string{"-p", "-"},
+			stdin:    "net.ipv4.ip_forward = 1\n",
+			stdout:   "net.ipv4.ip_forward = 1\n",
+			expected: map[string]string{"net.ipv4.ip_forward": "1"},
+		},
+		{
+			name:   "load missing file",
+			args:   []string{"-p", "{conf}missing.conf"},
+			stderr: "sysctl: cannot open \"{conf}missing.conf\": No such file or directory\n",
+			code:   1,
+		},
+		{
+			name: "system",
+			args: []string{"--system"},
+			configs: map[string]string{
+				"sysctl.d/10-a.conf": "net.ipv4.ip_forward = 1\n",
+				"sysctl.conf":        "kernel.hostname = test\n",
+			},
+			stdout: "* Applying {conf}sysctl.d/10-a.conf ...\n" +
+				"net.ipv4.ip_forward = 1\n" +
+				"* Applying {conf}sysctl.conf ...\n" +
+				"kernel.hostname = test\n",
+			expected: map[string]string{"net.ipv4.ip_forward": "1", "kernel.hostname": "test"},
+		},
+		{
+			name:   "no variables",
+			stderr: usage,
+			code:   1,
+		},
+		{
+			name:   "invalid option",
+			args:   []string{"-z"},
+			stderr: "sysctl: invalid option -- 'z'\n" + usage,
+			code:   1,
+		},
+		{
+			name:   "help",
+			args:   []string{"--help"},
+			stdout: usage,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir() + "/"
+			createFiles(t, root, sysctls)
+			// files are writable by root regardless of their mode,
+			// so read-only sysctls are recognized by their mode
+			if err := os.Chmod(root+"kernel/ostype", 0o444); err != nil {
+				t.Fatalf("could not change mode: %v", err)
+			}
+			conf := t.TempDir() + "/"
+			createFiles(t, conf, c.configs)
+			defer func(f string) { defaultConfigFile = f }(defaultConfigFile)
+			defaultConfigFile = conf + "sysctl.conf"
+			defer func(f func() ([]string, error)) { systemConfigFiles = f }(systemConfigFiles)
+			systemConfigFiles = func() ([]string, error) {
+				return filepath.Glob(conf + "sysctl.d/*.conf")
+			}
+			if c.unreadable != nil {
+				defer func(f func(string) (*sysctl.Client, error)) { newClient = f }(newClient)
+				newClient = func(string) (*sysctl.Client, error) {
+					f := sysctltest.New(sysctls)
+					f.SetWriteOnly(c.unreadable...)
+					return f.Client, nil
+				}
+			}
+
+			replacer := strings.NewReplacer("{root}", root, "{conf}", conf)
+			args := make([]string, len(c.args))
+			for i, a := range c.args {
+				args[i] = replacer.Replace(a)
+			}
+			var stdout, stderr bytes.Buffer
+			code := run(args, strings.NewReader(c.stdin), &stdout, &stderr, root)
+			if code != c.code {
+				t.Errorf("expected exit code %d, got %d", c.code, code)
+			}
+			if diff := cmp.Diff(replacer.Replace(c.stdout), stdout.String()); diff != "" {
+				t.Errorf("unexpected stdout (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(replacer.Replace(c.stderr), stderr.String()); diff != "" {
+				t.Errorf("unexpected stderr (-want +got):\n%s", diff)
+			}
+			for k, v := range c.expected {
+				got, err := os.ReadFile(root + keyPath(k))
+				if err != nil {
+					t.Fatalf("could not read key %s: %v", k, err)
+				}
+				if string(got) != v {
+					t.Errorf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+				}
+			}
+		})
+	}
+}
+
+func createFiles(t *testing.T, base string, files map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		p := filepath.Join(base, p)
+		dir := filepath.Dir(p)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatalf("could not create dir %s: %v", dir, err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not create file %s: %v", p, err)
+		}
+	}
+}
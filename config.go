@@ -3,22 +3,151 @@ package sysctl
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 )
 
 const sysctlConfPath = "/etc/sysctl.conf"
 
+// systemConfigDirs are the directories searched for sysctl configuration
+// files, in decreasing order of priority. This is the same list of
+// directories searched by systemd-sysctl.
+var systemConfigDirs = []string{
+	"/etc/sysctl.d",
+	"/run/sysctl.d",
+	"/usr/local/lib/sysctl.d",
+	"/usr/lib/sysctl.d",
+	"/lib/sysctl.d",
+}
+
+// Entry is a sysctl setting read from a configuration file.
+type Entry struct {
+	// Key is the sysctl key in canonical form, without any "-" prefix.
+	// It may contain a glob pattern.
+	Key string
+	// Value is the value of the sysctl. It is empty for exclusions.
+	Value string
+	// File is the path of the configuration file the entry was read from.
+	File string
+	// Line is the line number of the entry in File, starting from 1.
+	Line int
+	// IgnoreFailure is true if the key is prefixed with "-", in which case
+	// any failure to set the sysctl should be ignored.
+	IgnoreFailure bool
+	// Exclude is true if the key is prefixed with "-" and has no value,
+	// in which case the key is excluded from glob matches.
+	Exclude bool
+}
+
+// Location returns the location of the entry in the form file:line.
+func (e Entry) Location() string {
+	return fmt.Sprintf("%s:%d", e.File, e.Line)
+}
+
+// Override is an entry overriding the value set by an earlier entry
+// for the same key.
+type Override struct {
+	// Entry is the entry taking priority.
+	Entry Entry
+	// Overridden is the entry whose value is discarded.
+	Overridden Entry
+}
+
+// Config is an ordered list of sysctl settings read from configuration files.
+type Config struct {
+	// Entries are all entries in the order in which they were read,
+	// including those overridden by later entries for the same key.
+	Entries []Entry
+}
+
+// Effective returns the entries that take effect, i.e. the last entry read
+// for each key, in the order in which they were read.
+// This is the order in which entries are applied.
+func (c *Config) Effective() []Entry {
+	last := make(map[string]int, len(c.Entries))
+	for i, e := range c.Entries {
+		last[e.Key] = i
+	}
+	out := make([]Entry, 0, len(last))
+	for i, e := range c.Entries {
+		if last[e.Key] == i {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// Lookup returns the entry that takes effect for a given key.
+func (c *Config) Lookup(key string) (Entry, bool) {
+	key = CanonicalKey(key)
+	for i := len(c.Entries) - 1; i >= 0; i-- {
+		if c.Entries[i].Key == key {
+			return c.Entries[i], true
+		}
+	}
+	return Entry{}, false
+}
+
+// Map returns the values of the entries that take effect, keyed by
+// sysctl key. Keys containing glob patterns are returned unexpanded,
+// while keys excluded from glob matches are not returned.
+func (c *Config) Map() map[string]string {
+	out := make(map[string]string, len(c.Entries))
+	for _, e := range c.Effective() {
+		if e.Exclude {
+			continue
+		}
+		out[e.Key] = e.Value
+	}
+	return out
+}
+
+// Overrides returns all entries overriding an earlier entry for the same key
+// with a different value, in the order in which they were read.
+func (c *Config) Overrides() []Override {
+	var out []Override
+	prev := make(map[string]Entry, len(c.Entries))
+	for _, e := range c.Entries {
+		if p, ok := prev[e.Key]; ok && (p.Value != e.Value || p.Exclude != e.Exclude) {
+			out = append(out, Override{Entry: e, Overridden: p})
+		}
+		prev[e.Key] = e
+	}
+	return out
+}
+
+// isGlob returns whether a key is a glob pattern
+func isGlob(key string) bool {
+	return strings.ContainsAny(key, "*?[")
+}
+
+// matchKey returns whether a key matches a glob pattern.
+// As in systemd-sysctl, wildcards do not match across key components.
+func matchKey(pattern, key string) bool {
+	ok, _ := path.Match(keyToPath(pattern), keyToPath(key))
+	return ok
+}
+
 // parseConfig reads a sysctl configuration file and parses its content
-func parseConfig(path string, out map[string]string) error {
+func parseConfig(path string) ([]Entry, error) {
 	file, err := os.Open(path)
 	if err != nil {
-		return fmt.Errorf("could not open file: %v", err)
+		return nil, fmt.Errorf("could not open file: %w", err)
 	}
 	defer file.Close()
+	return parseEntries(file, path)
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
+// parseEntries parses the content of a sysctl configuration file
+// named path
+func parseEntries(r io.Reader, path string) ([]Entry, error) {
+	var out []Entry
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
 		line := scanner.Text()
 		parsed := strings.Split(line, "#")[0]
 		parsed = strings.Split(parsed, ";")[0]
@@ -27,33 +156,164 @@ func parseConfig(path string, out map[string]string) error {
 			continue
 		}
 		tokens := strings.Split(parsed, "=")
+		if len(tokens) == 1 && strings.HasPrefix(parsed, "-") {
+			k := CanonicalKey(strings.TrimSpace(parsed[1:]))
+			if k == "" {
+				return nil, &ParseError{File: path, Line: n, Text: line}
+			}
+			out = append(out, Entry{Key: k, File: path, Line: n, IgnoreFailure: true, Exclude: true})
+			continue
+		}
 		if len(tokens) != 2 {
-			return fmt.Errorf("could not parse line %s", line)
+			return nil, &ParseError{File: path, Line: n, Text: line}
 		}
 		k := strings.TrimSpace(tokens[0])
 		v := strings.TrimSpace(tokens[1])
-		out[k] = v
+		ignoreFailure := strings.HasPrefix(k, "-")
+		if ignoreFailure {
+			k = strings.TrimSpace(k[1:])
+		}
+		k = CanonicalKey(k)
+		if isGlob(k) {
+			if _, err := filepath.Match(k, ""); err != nil {
+				return nil, &ParseError{File: path, Line: n, Text: line, Err: err}
+			}
+		}
+		out = append(out, Entry{Key: k, Value: v, File: path, Line: n, IgnoreFailure: ignoreFailure})
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file: %v", err)
+		return nil, fmt.Errorf("error reading file: %w", err)
 	}
 
-	return nil
+	return out, nil
+}
+
+// ReadConfig reads sysctl settings from a list of sysctl configuration files.
+// Entries in the rightmost files take priority.
+// If no file is specified, entries are read from /etc/sysctl.conf.
+func ReadConfig(files ...string) (*Config, error) {
+	if len(files) == 0 {
+		files = []string{sysctlConfPath}
+	}
+	config := &Config{}
+	for _, f := range files {
+		entries, err := parseConfig(f)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse file %s: %w", f, err)
+		}
+		config.Entries = append(config.Entries, entries...)
+	}
+	return config, nil
+}
+
+// ParseConfig reads sysctl settings in the format of sysctl configuration
+// files from a reader, such as standard input. The name is recorded as
+// the file of all entries.
+// Syntax errors are of type *ParseError.
+func ParseConfig(r io.Reader, name string) (*Config, error) {
+	entries, err := parseEntries(r, name)
+	if err != nil {
+		return nil, err
+	}
+	return &Config{Entries: entries}, nil
 }
 
 // LoadConfig gets sysctl values from a list of sysctl configuration files.
 // The values in the rightmost files take priority.
 // If no file is specified, values are read from /etc/sysctl.conf.
+// Keys prefixed with "-" are returned without the prefix.
+// Keys containing glob patterns are returned unexpanded, while
+// keys excluded from glob matches are not returned.
 func LoadConfig(files ...string) (map[string]string, error) {
-	if len(files) == 0 {
-		files = []string{sysctlConfPath}
+	config, err := ReadConfig(files...)
+	if err != nil {
+		return nil, err
 	}
-	out := make(map[string]string)
-	for _, f := range files {
-		if err := parseConfig(f, out); err != nil {
-			return nil, fmt.Errorf("could not parse file %s: %v", f, err)
+	return config.Map(), nil
+}
+
+// SystemConfigFiles returns the sysctl configuration files that
+// systemd-sysctl would apply on boot, in the order in which they are applied.
+// Files are discovered in /etc/sysctl.d, /run/sysctl.d, /usr/local/lib/sysctl.d,
+// /usr/lib/sysctl.d and /lib/sysctl.d and sorted by file name.
+// A file masks all files with the same name in lower priority directories
+// and a file that is a symlink to /dev/null is masked entirely.
+func SystemConfigFiles() ([]string, error) {
+	return findConfigFiles(systemConfigDirs)
+}
+
+// findConfigFiles returns all *.conf files in dirs, sorted by file name.
+// Dirs are in decreasing order of priority.
+func findConfigFiles(dirs []string) ([]string, error) {
+	found := make(map[string]string)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("could not read directory %s: %w", dir, err)
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if !strings.HasSuffix(name, ".conf") {
+				continue
+			}
+			if _, ok := found[name]; ok {
+				// masked by a file in a higher priority directory
+				continue
+			}
+			found[name] = filepath.Join(dir, name)
 		}
 	}
-	return out, nil
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		path := found[name]
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				// dangling symlink
+				continue
+			}
+			return nil, fmt.Errorf("could not get file info on %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			// this includes files masked by a symlink to /dev/null,
+			// which is a character device
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// ReadSystemConfig reads sysctl settings from all system configuration files,
+// as returned by SystemConfigFiles.
+// Entries in files applied later take priority.
+func ReadSystemConfig() (*Config, error) {
+	files, err := SystemConfigFiles()
+	if err != nil {
+		return nil, fmt.Errorf("could not find configuration files: %w", err)
+	}
+	if len(files) == 0 {
+		return &Config{}, nil
+	}
+	return ReadConfig(files...)
+}
+
+// LoadSystemConfig gets sysctl values from all system configuration files,
+// as returned by SystemConfigFiles.
+// The values in files applied later take priority.
+func LoadSystemConfig() (map[string]string, error) {
+	config, err := ReadSystemConfig()
+	if err != nil {
+		return nil, err
+	}
+	return config.Map(), nil
 }
@@ -1,6 +1,9 @@
 package sysctl
 
 import (
+	"errors"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -12,29 +15,53 @@ func Test_parseConfig(t *testing.T) {
 		name string
 		path string
 		ok   bool
-		out  map[string]string
+		out  []Entry
 	}{
 		{
 			name: "ok",
 			path: "testdata/config/sysctl-correct.conf",
 			ok:   true,
-			out: map[string]string{
-				"kernel.domainname": "example.com",
-				"kernel.modprobe":   "/sbin/mod probe",
-				"kernel.hostname":   "example.com",
+			out: []Entry{
+				{Key: "kernel.domainname", Value: "example.com", Line: 3},
+				{Key: "kernel.modprobe", Value: "/sbin/mod probe", Line: 5},
+				{Key: "kernel.hostname", Value: "example.com", Line: 8},
 			},
 		},
+		{
+			name: "ignore failure",
+			path: "testdata/config/sysctl-ignore-failure.conf",
+			ok:   true,
+			out: []Entry{
+				{Key: "kernel.domainname", Value: "example.com", Line: 1},
+				{Key: "net.ipv4.missing", Value: "1", Line: 3, IgnoreFailure: true},
+				{Key: "net.ipv4.missing_too", Value: "2", Line: 4, IgnoreFailure: true},
+			},
+		},
+		{
+			name: "glob",
+			path: "testdata/config/sysctl-glob.conf",
+			ok:   true,
+			out: []Entry{
+				{Key: "net.ipv4.conf.*.rp_filter", Value: "2", Line: 2},
+				{Key: "net.ipv4.conf.lo.rp_filter", Line: 3, IgnoreFailure: true, Exclude: true},
+				{Key: "net.ipv4.conf.all.rp_filter", Value: "1", Line: 4},
+				{Key: "net.ipv4.conf.eth0/100.rp_filter", Value: "0", Line: 5},
+			},
+		},
+		{
+			name: "invalid glob",
+			path: "testdata/config/sysctl-invalid-glob.conf",
+			ok:   false,
+		},
 		{
 			name: "empty",
 			path: "testdata/config/sysctl-empty.conf",
 			ok:   true,
-			out:  map[string]string{},
 		},
 		{
 			name: "only-comments",
 			path: "testdata/config/sysctl-only-comments.conf",
 			ok:   true,
-			out:  map[string]string{},
 		},
 		{
 			name: "malformatted",
@@ -49,8 +76,7 @@ func Test_parseConfig(t *testing.T) {
 	}
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			out := make(map[string]string)
-			err := parseConfig(c.path, out)
+			out, err := parseConfig(c.path)
 			if c.ok && err != nil {
 				t.Fatalf("error parsing: %v", err)
 			}
@@ -61,6 +87,9 @@ func Test_parseConfig(t *testing.T) {
 				t.Logf("err: %v", err)
 				return
 			}
+			for i := range c.out {
+				c.out[i].File = c.path
+			}
 			if diff := cmp.Diff(c.out, out); diff != "" {
 				t.Fatalf("unexpected output from %s (-want +got):\n%s", c.path, diff)
 			}
@@ -68,6 +97,30 @@ func Test_parseConfig(t *testing.T) {
 	}
 }
 
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig(strings.NewReader("a = 1\n-b = 2\n"), "stdin")
+	if err != nil {
+		t.Fatalf("could not parse config: %v", err)
+	}
+	expected := &Config{
+		Entries: []Entry{
+			{Key: "a", Value: "1", File: "stdin", Line: 1},
+			{Key: "b", Value: "2", File: "stdin", Line: 2, IgnoreFailure: true},
+		},
+	}
+	if diff := cmp.Diff(expected, config); diff != "" {
+		t.Fatalf("unexpected config (-want +got):\n%s", diff)
+	}
+	_, err = ParseConfig(strings.NewReader("a = 1\nb\n"), "stdin")
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if perr.Line != 2 {
+		t.Fatalf("unexpected error line: %d", perr.Line)
+	}
+}
+
 func TestLoadConfig(t *testing.T) {
 	cases := []struct {
 		name  string
@@ -80,6 +133,16 @@ func TestLoadConfig(t *testing.T) {
 			paths: []string{"testdata/config/not-found"},
 			ok:    false,
 		},
+		{
+			name:  "glob",
+			paths: []string{"testdata/config/sysctl-glob.conf"},
+			ok:    true,
+			out: map[string]string{
+				"net.ipv4.conf.*.rp_filter":        "2",
+				"net.ipv4.conf.all.rp_filter":      "1",
+				"net.ipv4.conf.eth0/100.rp_filter": "0",
+			},
+		},
 		{
 			name: "ok",
 			paths: []string{
@@ -111,3 +174,231 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestReadConfig(t *testing.T) {
+	config, err := ReadConfig(
+		"testdata/config/sysctl-a.conf",
+		"testdata/config/sysctl-correct.conf",
+		"testdata/config/sysctl-b.conf",
+	)
+	if err != nil {
+		t.Fatalf("error reading config: %v", err)
+	}
+
+	effective := []Entry{
+		{Key: "kernel.modprobe", Value: "/sbin/mod probe", File: "testdata/config/sysctl-correct.conf", Line: 5},
+		{Key: "kernel.hostname", Value: "example.com", File: "testdata/config/sysctl-correct.conf", Line: 8},
+		{Key: "kernel.domainname", Value: "b.com", File: "testdata/config/sysctl-b.conf", Line: 1},
+	}
+	if diff := cmp.Diff(effective, config.Effective()); diff != "" {
+		t.Fatalf("unexpected effective entries (-want +got):\n%s", diff)
+	}
+
+	overrides := []Override{
+		{
+			Entry:      Entry{Key: "kernel.domainname", Value: "example.com", File: "testdata/config/sysctl-correct.conf", Line: 3},
+			Overridden: Entry{Key: "kernel.domainname", Value: "a.com", File: "testdata/config/sysctl-a.conf", Line: 1},
+		},
+		{
+			Entry:      Entry{Key: "kernel.domainname", Value: "b.com", File: "testdata/config/sysctl-b.conf", Line: 1},
+			Overridden: Entry{Key: "kernel.domainname", Value: "example.com", File: "testdata/config/sysctl-correct.conf", Line: 3},
+		},
+	}
+	if diff := cmp.Diff(overrides, config.Overrides()); diff != "" {
+		t.Fatalf("unexpected overrides (-want +got):\n%s", diff)
+	}
+
+	e, ok := config.Lookup("kernel.domainname")
+	if !ok {
+		t.Fatal("kernel.domainname not found")
+	}
+	if loc := e.Location(); loc != "testdata/config/sysctl-b.conf:1" {
+		t.Fatalf("unexpected location: %s", loc)
+	}
+	if _, ok := config.Lookup("kernel.missing"); ok {
+		t.Fatal("kernel.missing unexpectedly found")
+	}
+
+	values := map[string]string{
+		"kernel.domainname": "b.com",
+		"kernel.modprobe":   "/sbin/mod probe",
+		"kernel.hostname":   "example.com",
+	}
+	if diff := cmp.Diff(values, config.Map()); diff != "" {
+		t.Fatalf("unexpected values (-want +got):\n%s", diff)
+	}
+}
+
+func Test_matchKey(t *testing.T) {
+	cases := []struct {
+		pattern string
+		key     string
+		match   bool
+	}{
+		{
+			pattern: "net.ipv4.conf.*.rp_filter",
+			key:     "net.ipv4.conf.eth0.rp_filter",
+			match:   true,
+		},
+		{
+			pattern: "net.ipv4.conf.*.rp_filter",
+			key:     "net.ipv4.conf.eth0.accept_local",
+			match:   false,
+		},
+		{
+			pattern: "net.*",
+			key:     "net.ipv4.ip_forward",
+			match:   false,
+		},
+		{
+			pattern: "net.ipv?.ip_forward",
+			key:     "net.ipv4.ip_forward",
+			match:   true,
+		},
+		{
+			pattern: "net.ipv4.conf.*.rp_filter",
+			key:     "net.ipv4.conf.eth0/100.rp_filter",
+			match:   true,
+		},
+		{
+			pattern: "kernel.sched_[a-c]*",
+			key:     "kernel.sched_autogroup_enabled",
+			match:   true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern+" "+c.key, func(t *testing.T) {
+			if got := matchKey(c.pattern, c.key); got != c.match {
+				t.Fatalf("expected: %v. Got: %v", c.match, got)
+			}
+		})
+	}
+}
+
+func Test_findConfigFiles(t *testing.T) {
+	cases := []struct {
+		name     string
+		files    map[string]string
+		masked   []string
+		dirs     []string
+		expected []string
+	}{
+		{
+			name:     "no dirs",
+			dirs:     []string{"etc", "run", "lib"},
+			expected: []string{},
+		},
+		{
+			name: "sorted by file name",
+			files: map[string]string{
+				"lib/10-b.conf": "b = 1",
+				"etc/20-a.conf": "a = 1",
+				"run/30-c.conf": "c = 1",
+			},
+			dirs:     []string{"etc", "run", "lib"},
+			expected: []string{"lib/10-b.conf", "etc/20-a.conf", "run/30-c.conf"},
+		},
+		{
+			name: "higher priority dir masks lower ones",
+			files: map[string]string{
+				"etc/10-a.conf": "a = 1",
+				"run/10-a.conf": "a = 2",
+				"lib/10-a.conf": "a = 3",
+				"lib/20-b.conf": "b = 3",
+			},
+			dirs:     []string{"etc", "run", "lib"},
+			expected: []string{"etc/10-a.conf", "lib/20-b.conf"},
+		},
+		{
+			name: "masked by /dev/null",
+			files: map[string]string{
+				"lib/10-a.conf": "a = 1",
+				"lib/20-b.conf": "b = 1",
+			},
+			masked:   []string{"etc/10-a.conf"},
+			dirs:     []string{"etc", "run", "lib"},
+			expected: []string{"lib/20-b.conf"},
+		},
+		{
+			name: "non conf files ignored",
+			files: map[string]string{
+				"etc/10-a.conf":     "a = 1",
+				"etc/README":        "",
+				"etc/20-b.conf.bak": "b = 1",
+			},
+			dirs:     []string{"etc"},
+			expected: []string{"etc/10-a.conf"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			base := t.TempDir()
+			createConfigFiles(t, base, c.files)
+			for _, m := range c.masked {
+				p := filepath.Join(base, m)
+				if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+					t.Fatalf("could not create dir: %v", err)
+				}
+				if err := os.Symlink("/dev/null", p); err != nil {
+					t.Fatalf("could not create symlink %s: %v", p, err)
+				}
+			}
+			dirs := make([]string, len(c.dirs))
+			for i, d := range c.dirs {
+				dirs[i] = filepath.Join(base, d)
+			}
+			got, err := findConfigFiles(dirs)
+			if err != nil {
+				t.Fatalf("could not find config files: %v", err)
+			}
+			expected := make([]string, len(c.expected))
+			for i, f := range c.expected {
+				expected[i] = filepath.Join(base, f)
+			}
+			if diff := cmp.Diff(expected, got); diff != "" {
+				t.Fatalf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLoadSystemConfig(t *testing.T) {
+	base := t.TempDir()
+	createConfigFiles(t, base, map[string]string{
+		"etc/10-a.conf": "a = etc",
+		"lib/10-a.conf": "a = lib",
+		"lib/20-b.conf": "a = lib\nb = lib",
+		"run/30-c.conf": "b = run",
+	})
+	defer func(dirs []string) { systemConfigDirs = dirs }(systemConfigDirs)
+	systemConfigDirs = []string{
+		filepath.Join(base, "etc"),
+		filepath.Join(base, "run"),
+		filepath.Join(base, "lib"),
+	}
+	got, err := LoadSystemConfig()
+	if err != nil {
+		t.Fatalf("could not load system config: %v", err)
+	}
+	expected := map[string]string{
+		"a": "lib",
+		"b": "run",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected output (-want +got):\n%s", diff)
+	}
+}
+
+func createConfigFiles(t *testing.T, base string, files map[string]string) {
+	t.Helper()
+	for p, content := range files {
+		p := filepath.Join(base, p)
+		dir := filepath.Dir(p)
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatalf("could not create dir %s: %v", dir, err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not create file %s: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package sysctl
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ValueDiff is a difference in the value of a sysctl between two states.
+type ValueDiff struct {
+	// Key is the sysctl key.
+	Key string `json:"key"`
+	// Old is the value in the first state.
+	// It is empty if the sysctl was added.
+	Old string `json:"old"`
+	// New is the value in the second state.
+	// It is empty if the sysctl was removed.
+	New string `json:"new"`
+}
+
+// StateDiff is the difference between two sysctl states.
+// It can be encoded as JSON.
+type StateDiff struct {
+	// Added are the sysctls only present in the second state,
+	// sorted by key.
+	Added []ValueDiff `json:"added"`
+	// Removed are the sysctls only present in the first state,
+	// sorted by key.
+	Removed []ValueDiff `json:"removed"`
+	// Changed are the sysctls present in both states with different
+	// values, sorted by key.
+	Changed []ValueDiff `json:"changed"`
+}
+
+// Diff returns the difference between two sysctl states, such as those
+// returned by GetAll, LoadConfig or Config.Map, or the values of a Snapshot.
+// Keys are compared in canonical form and values are compared regardless
+// of differences in whitespace, so that for example "4096\t131072" and
+// "4096 131072" are considered equal.
+func Diff(from, to map[string]string) *StateDiff {
+	from = canonicalValues(from)
+	to = canonicalValues(to)
+	d := &StateDiff{
+		Added:   []ValueDiff{},
+		Removed: []ValueDiff{},
+		Changed: []ValueDiff{},
+	}
+	for k, old := range from {
+		v, ok := to[k]
+		switch {
+		case !ok:
+			d.Removed = append(d.Removed, ValueDiff{Key: k, Old: old})
+		case normalizeValue(old) != normalizeValue(v):
+			d.Changed = append(d.Changed, ValueDiff{Key: k, Old: old, New: v})
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			d.Added = append(d.Added, ValueDiff{Key: k, New: v})
+		}
+	}
+	for _, diffs := range [][]ValueDiff{d.Added, d.Removed, d.Changed} {
+		sort.Slice(diffs, func(i, j int) bool { return diffs[i].Key < diffs[j].Key })
+	}
+	return d
+}
+
+// canonicalValues returns a copy of a map of sysctl values
+// with keys in canonical form
+func canonicalValues(values map[string]string) map[string]string {
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[CanonicalKey(k)] = v
+	}
+	return out
+}
+
+// Empty returns whether there is no difference between the two states.
+func (d *StateDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String returns a human-readable description of the difference in
+// unified diff style, without header. See Unified for details.
+func (d *StateDiff) String() string {
+	return d.lines()
+}
+
+// Unified returns a human-readable description of the difference in
+// unified diff style, with the given names of the two states in the header.
+// Sysctls are sorted by key, with sysctls removed or changed in the second
+// state prefixed by "-" and sysctls added or changed prefixed by "+".
+func (d *StateDiff) Unified(from, to string) string {
+	return fmt.Sprintf("--- %s\n+++ %s\n%s", from, to, d.lines())
+}
+
+func (d *StateDiff) lines() string {
+	type line struct {
+		ValueDiff
+		old, new bool
+	}
+	all := make([]line, 0, len(d.Added)+len(d.Removed)+len(d.Changed))
+	for _, v := range d.Added {
+		all = append(all, line{ValueDiff: v, new: true})
+	}
+	for _, v := range d.Removed {
+		all = append(all, line{ValueDiff: v, old: true})
+	}
+	for _, v := range d.Changed {
+		all = append(all, line{ValueDiff: v, old: true, new: true})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Key < all[j].Key })
+	var b strings.Builder
+	for _, l := range all {
+		if l.old {
+			fmt.Fprintf(&b, "-%s = %s\n", l.Key, l.Old)
+		}
+		if l.new {
+			fmt.Fprintf(&b, "+%s = %s\n", l.Key, l.New)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,99 @@
+package sysctl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	from := map[string]string{
+		"kernel.pid_max":      "32768",
+		"net.ipv4.tcp_rmem":   "4096\t131072\t6291456",
+		"net.ipv4.ip_forward": "0",
+		"vm.swappiness":       "60",
+	}
+	to := map[string]string{
+		"kernel.pid_max":                   "4194304",
+		"net/ipv4/tcp_rmem":                "4096 131072  6291456",
+		"net.ipv4.ip_forward":              "0",
+		"net/ipv4/conf/eth0.100/rp_filter": "2",
+	}
+	d := Diff(from, to)
+	expected := &StateDiff{
+		Added: []ValueDiff{
+			{Key: "net.ipv4.conf.eth0/100.rp_filter", New: "2"},
+		},
+		Removed: []ValueDiff{
+			{Key: "vm.swappiness", Old: "60"},
+		},
+		Changed: []ValueDiff{
+			{Key: "kernel.pid_max", Old: "32768", New: "4194304"},
+		},
+	}
+	if diff := cmp.Diff(expected, d); diff != "" {
+		t.Fatalf("unexpected diff (-want +got):\n%s", diff)
+	}
+	if d.Empty() {
+		t.Fatal("diff unexpectedly empty")
+	}
+
+	unified := "--- a\n+++ b\n" +
+		"-kernel.pid_max = 32768\n" +
+		"+kernel.pid_max = 4194304\n" +
+		"+net.ipv4.conf.eth0/100.rp_filter = 2\n" +
+		"-vm.swappiness = 60\n"
+	if diff := cmp.Diff(unified, d.Unified("a", "b")); diff != "" {
+		t.Fatalf("unexpected unified output (-want +got):\n%s", diff)
+	}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("could not marshal diff: %v", err)
+	}
+	var decoded StateDiff
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("could not unmarshal diff: %v", err)
+	}
+	if diff := cmp.Diff(expected, &decoded); diff != "" {
+		t.Fatalf("unexpected decoded diff (-want +got):\n%s", diff)
+	}
+}
+
+func TestDiffEmpty(t *testing.T) {
+	d := Diff(map[string]string{"a": "1 2"}, map[string]string{"a": "1\t2"})
+	if !d.Empty() {
+		t.Fatalf("unexpected diff:\n%s", d)
+	}
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("could not marshal diff: %v", err)
+	}
+	expected := `{"added":[],"removed":[],"changed":[]}`
+	if string(b) != expected {
+		t.Fatalf("expected: %s. Got: %s", expected, b)
+	}
+}
+
+func TestDiffJSONEmptyValue(t *testing.T) {
+	d := Diff(map[string]string{"a": "", "b": "1"}, map[string]string{"a": "1", "c": ""})
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatalf("could not marshal diff: %v", err)
+	}
+	// empty values are encoded rather than omitted
+	expected := `{"added":[{"key":"c","old":"","new":""}],` +
+		`"removed":[{"key":"b","old":"1","new":""}],` +
+		`"changed":[{"key":"a","old":"","new":"1"}]}`
+	if string(b) != expected {
+		t.Fatalf("expected: %s. Got: %s", expected, b)
+	}
+	var decoded StateDiff
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("could not unmarshal diff: %v", err)
+	}
+	if diff := cmp.Diff(d, &decoded); diff != "" {
+		t.Fatalf("unexpected decoded diff (-want +got):\n%s", diff)
+	}
+}
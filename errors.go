@@ -0,0 +1,150 @@
+package sysctl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+)
+
+var (
+	// ErrNotExist is returned when a sysctl does not exist.
+	ErrNotExist = errors.New("sysctl does not exist")
+	// ErrPermission is returned when the caller has no permission
+	// to read or write a sysctl.
+	ErrPermission = errors.New("permission denied")
+	// ErrInvalidValue is returned when the kernel rejects the value
+	// written to a sysctl.
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrInvalidKey is returned when a key is malformed or refers to
+	// a file outside of the sysctl root.
+	ErrInvalidKey = errors.New("invalid key")
+	// ErrReadOnly is returned when writing a sysctl through a read-only
+	// client, such as one created with NewCaptureClient.
+	// Errors matching ErrReadOnly also match ErrPermission.
+	ErrReadOnly = errors.New("read-only client")
+)
+
+// KeyError records an error occurred reading or writing a sysctl.
+// It can be matched against ErrNotExist, ErrPermission and ErrInvalidValue
+// using errors.Is, as well as against the underlying error.
+type KeyError struct {
+	// Key is the sysctl key.
+	Key string
+	// Op is the operation that failed, either "get" or "set".
+	Op string
+	// Err is the underlying error, usually an *os.PathError.
+	Err error
+}
+
+func (e *KeyError) Error() string {
+	return fmt.Sprintf("could not %s %s: %v", e.Op, e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error matches one of the sentinel errors
+// of this package.
+func (e *KeyError) Is(target error) bool {
+	switch target {
+	case ErrNotExist:
+		return errors.Is(e.Err, fs.ErrNotExist)
+	case ErrPermission:
+		return errors.Is(e.Err, fs.ErrPermission) || errors.Is(e.Err, ErrReadOnly)
+	case ErrInvalidValue:
+		return errors.Is(e.Err, syscall.EINVAL)
+	}
+	return false
+}
+
+// CanceledError records an operation on multiple sysctls interrupted
+// because its context was canceled or its deadline expired.
+// It can be matched against the error of the context, i.e.
+// context.Canceled or context.DeadlineExceeded, using errors.Is.
+type CanceledError struct {
+	// Op is the operation that was interrupted, either "get", "list",
+	// i.e. listing sysctls, or "apply".
+	Op string
+	// Done is the number of sysctls processed before the interruption.
+	Done int
+	// Total is the number of sysctls to process, or -1 if unknown.
+	Total int
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *CanceledError) Error() string {
+	if e.Total < 0 {
+		return fmt.Sprintf("%s interrupted after %d sysctls: %v", e.Op, e.Done, e.Err)
+	}
+	return fmt.Sprintf("%s interrupted after %d of %d sysctls: %v", e.Op, e.Done, e.Total, e.Err)
+}
+
+// Unwrap returns the error of the context.
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError records an error occurred parsing a configuration file.
+type ParseError struct {
+	// File is the path of the configuration file.
+	File string
+	// Line is the line number, starting from 1.
+	Line int
+	// Text is the content of the line.
+	Text string
+	// Err is the underlying error, if any.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s:%d: could not parse line %q: %v", e.File, e.Line, e.Text, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: could not parse line %q", e.File, e.Line, e.Text)
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ValueError records an error occurred converting the value of a sysctl
+// to a given type.
+type ValueError struct {
+	// Key is the sysctl key.
+	Key string
+	// Value is the value that could not be converted.
+	Value string
+	// Type is the name of the type the value was converted to.
+	Type string
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ValueError) Error() string {
+	return fmt.Sprintf("value %q of %s is not a valid %s: %v", e.Value, e.Key, e.Type, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// MismatchError is returned when the value of a sysctl read back after
+// writing it differs from the value written.
+type MismatchError struct {
+	// Key is the sysctl key.
+	Key string
+	// Requested is the value written.
+	Requested string
+	// Actual is the value read back.
+	Actual string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("value of %s is %q instead of %q", e.Key, e.Actual, e.Requested)
+}
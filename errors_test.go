@@ -0,0 +1,136 @@
+package sysctl
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestKeyErrorIs(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		target   error
+		expected bool
+	}{
+		{
+			name:     "not exist",
+			err:      &os.PathError{Op: "open", Path: "/proc/sys/a", Err: syscall.ENOENT},
+			target:   ErrNotExist,
+			expected: true,
+		},
+		{
+			name:     "not exist underlying",
+			err:      &os.PathError{Op: "open", Path: "/proc/sys/a", Err: syscall.ENOENT},
+			target:   fs.ErrNotExist,
+			expected: true,
+		},
+		{
+			name:     "permission denied EACCES",
+			err:      &os.PathError{Op: "open", Path: "/proc/sys/a", Err: syscall.EACCES},
+			target:   ErrPermission,
+			expected: true,
+		},
+		{
+			name:     "permission denied EPERM",
+			err:      &os.PathError{Op: "write", Path: "/proc/sys/a", Err: syscall.EPERM},
+			target:   ErrPermission,
+			expected: true,
+		},
+		{
+			name:     "invalid value",
+			err:      &os.PathError{Op: "write", Path: "/proc/sys/a", Err: syscall.EINVAL},
+			target:   ErrInvalidValue,
+			expected: true,
+		},
+		{
+			name:     "invalid value underlying",
+			err:      &os.PathError{Op: "write", Path: "/proc/sys/a", Err: syscall.EINVAL},
+			target:   syscall.EINVAL,
+			expected: true,
+		},
+		{
+			name:     "read-only",
+			err:      &os.PathError{Op: "write", Path: "a", Err: ErrReadOnly},
+			target:   ErrReadOnly,
+			expected: true,
+		},
+		{
+			name:     "read-only permission",
+			err:      &os.PathError{Op: "write", Path: "a", Err: ErrReadOnly},
+			target:   ErrPermission,
+			expected: true,
+		},
+		{
+			name:     "mismatch",
+			err:      &os.PathError{Op: "write", Path: "/proc/sys/a", Err: syscall.EINVAL},
+			target:   ErrNotExist,
+			expected: false,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := &KeyError{Key: "a", Op: "set", Err: c.err}
+			if got := errors.Is(err, c.target); got != c.expected {
+				t.Fatalf("expected: %v. Got: %v", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestClientGetKeyError(t *testing.T) {
+	cl, err := NewClient("testdata/client/ok")
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	_, err = cl.Get("missing")
+	if err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+	var kerr *KeyError
+	if !errors.As(err, &kerr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if kerr.Key != "missing" || kerr.Op != "get" {
+		t.Fatalf("unexpected error: %+v", kerr)
+	}
+	if !errors.Is(err, ErrNotExist) {
+		t.Fatalf("expected error to match ErrNotExist: %v", err)
+	}
+}
+
+func TestLoadConfigParseError(t *testing.T) {
+	_, err := LoadConfig("testdata/config/sysctl-error.conf")
+	if err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+	var perr *ParseError
+	if !errors.As(err, &perr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	expected := ParseError{
+		File: "testdata/config/sysctl-error.conf",
+		Line: 1,
+		Text: "kernel.domainname.example.com",
+	}
+	if *perr != expected {
+		t.Fatalf("unexpected error: %+v", perr)
+	}
+}
+
+func TestClientSetNotExist(t *testing.T) {
+	path := t.TempDir()
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	if err := cl.Set("net.ipv4.nonexistent", "1"); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("expected error matching ErrNotExist, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(path, "net/ipv4/nonexistent")); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("file of missing sysctl was created: %v", err)
+	}
+}
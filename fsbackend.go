@@ -0,0 +1,81 @@
+package sysctl
+
+import (
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+// WritableFS is a file system whose files can be written, other than read.
+// A Client created with NewFSClient on a WritableFS writes sysctls
+// through it.
+type WritableFS interface {
+	fs.FS
+	// WriteFile writes data to the named file, replacing its content.
+	// Names follow the same conventions of fs.FS.
+	WriteFile(name string, data []byte) error
+}
+
+// NewFSClient returns a new Client reading sysctls from the files of fsys,
+// laid out as in /proc/sys, e.g. an fstest.MapFS, an embed.FS holding a
+// copy of /proc/sys or a sub-tree of an archive obtained with fs.Sub.
+// Sysctls are written only if fsys implements WritableFS, otherwise all
+// writes fail with an error matching ErrReadOnly and all sysctls
+// are considered read-only.
+func NewFSClient(fsys fs.FS) *Client {
+	return NewBackendClient(fsBackend{fsys: fsys})
+}
+
+// fsBackend is a Backend storing sysctls in the files of an fs.FS
+type fsBackend struct {
+	fsys fs.FS
+}
+
+func (b fsBackend) Read(key string) (string, error) {
+	data, err := fs.ReadFile(b.fsys, keyToPath(key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (b fsBackend) Write(key, value string) error {
+	name := keyToPath(key)
+	w, ok := b.fsys.(WritableFS)
+	if !ok {
+		return &fs.PathError{Op: "write", Path: name, Err: ErrReadOnly}
+	}
+	if _, err := fs.Stat(b.fsys, name); err != nil {
+		return err
+	}
+	return w.WriteFile(name, []byte(value))
+}
+
+func (b fsBackend) Keys() ([]string, error) {
+	var keys []string
+	err := fs.WalkDir(b.fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			keys = append(keys, pathToKey(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (b fsBackend) Mode(key string) (fs.FileMode, error) {
+	info, err := fs.Stat(b.fsys, keyToPath(key))
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := b.fsys.(WritableFS); !ok {
+		return info.Mode() &^ 0o222, nil
+	}
+	return info.Mode(), nil
+}
@@ -0,0 +1,159 @@
+package sysctl
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// writableMapFS is a MapFS implementing WritableFS
+type writableMapFS struct {
+	fstest.MapFS
+}
+
+func (m writableMapFS) WriteFile(name string, data []byte) error {
+	f, ok := m.MapFS[name]
+	if !ok {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrNotExist}
+	}
+	f.Data = data
+	return nil
+}
+
+func TestFSClient(t *testing.T) {
+	fsys := fstest.MapFS{
+		"net/ipv4/ip_forward":              {Data: []byte("1\n"), Mode: 0o644},
+		"net/ipv4/conf/eth0.100/rp_filter": {Data: []byte("2\n"), Mode: 0o644},
+		"kernel/osrelease":                 {Data: []byte("6.1.0\n"), Mode: 0o444},
+	}
+	cases := []struct {
+		name string
+		fsys fs.FS
+		set  map[string]string
+		res  map[string]string
+		ok   bool
+	}{
+		{
+			name: "read-only",
+			fsys: fsys,
+			set:  map[string]string{"net.ipv4.ip_forward": "0"},
+			res: map[string]string{
+				"net.ipv4.ip_forward":              "1",
+				"net.ipv4.conf.eth0/100.rp_filter": "2",
+				"kernel.osrelease":                 "6.1.0",
+			},
+		},
+		{
+			name: "writable",
+			fsys: writableMapFS{fsys},
+			set:  map[string]string{"net.ipv4.ip_forward": "0"},
+			res: map[string]string{
+				"net.ipv4.ip_forward":              "0",
+				"net.ipv4.conf.eth0/100.rp_filter": "2",
+				"kernel.osrelease":                 "6.1.0",
+			},
+			ok: true,
+		},
+		{
+			name: "writable missing",
+			fsys: writableMapFS{fsys},
+			set:  map[string]string{"net.ipv4.tcp_syncookies": "1"},
+			res: map[string]string{
+				"net.ipv4.ip_forward":              "1",
+				"net.ipv4.conf.eth0/100.rp_filter": "2",
+				"kernel.osrelease":                 "6.1.0",
+			},
+		},
+		{
+			name: "sub",
+			fsys: func() fs.FS {
+				sub, err := fs.Sub(fstest.MapFS{
+					"proc/sys/vm/swappiness": {Data: []byte("60\n")},
+				}, "proc/sys")
+				if err != nil {
+					t.Fatalf("could not create sub fs: %v", err)
+				}
+				return sub
+			}(),
+			res: map[string]string{"vm.swappiness": "60"},
+			ok:  true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// copy files, so that writes do not leak across cases
+			if w, ok := c.fsys.(writableMapFS); ok {
+				m := fstest.MapFS{}
+				for name, f := range w.MapFS {
+					cp := *f
+					m[name] = &cp
+				}
+				c.fsys = writableMapFS{m}
+			}
+			cl := NewFSClient(c.fsys)
+			var err error
+			for k, v := range c.set {
+				if err = cl.Set(k, v); err != nil {
+					break
+				}
+			}
+			if c.ok && err != nil {
+				t.Fatalf("could not set value: %v", err)
+			}
+			if !c.ok && err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			if err != nil {
+				t.Logf("err: %v", err)
+			}
+			got, err := cl.GetAll()
+			if err != nil {
+				t.Fatalf("could not get values: %v", err)
+			}
+			if diff := cmp.Diff(c.res, got); diff != "" {
+				t.Fatalf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFSClientErrors(t *testing.T) {
+	cl := NewFSClient(fstest.MapFS{
+		"kernel/osrelease": {Data: []byte("6.1.0\n"), Mode: 0o444},
+	})
+	if _, err := cl.Get("kernel.hostname"); !errors.Is(err, ErrNotExist) {
+		t.Fatalf("expected error matching ErrNotExist, got: %v", err)
+	}
+	if err := cl.Set("kernel.osrelease", "x"); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("expected error matching ErrReadOnly, got: %v", err)
+	}
+	if _, err := cl.Get("../kernel/osrelease"); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("expected error matching ErrInvalidKey, got: %v", err)
+	}
+	plan, err := cl.PlanConfig(&Config{Entries: []Entry{{Key: "kernel.osrelease", Value: "x"}}})
+	if err != nil {
+		t.Fatalf("could not plan config: %v", err)
+	}
+	if len(plan.Changes) != 1 || !plan.Changes[0].ReadOnly {
+		t.Fatalf("expected read-only change, got: %+v", plan.Changes)
+	}
+}
+
+func TestFSClientDirFS(t *testing.T) {
+	cl := NewFSClient(os.DirFS("testdata/client/ok"))
+	got, err := cl.GetPattern("^d\\.d\\.")
+	if err != nil {
+		t.Fatalf("could not get values: %v", err)
+	}
+	expected := map[string]string{
+		"d.d.f1": "value of d.d.f1",
+		"d.d.f2": "value of d.d.f2",
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected output (-want +got):\n%s", diff)
+	}
+}
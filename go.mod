@@ -1,19 +1,30 @@
 module github.com/lorenzosaino/go-sysctl
 
-go 1.18
+go 1.24
 
 require (
 	github.com/BurntSushi/toml v1.2.1 // indirect
-	github.com/google/go-cmp v0.5.9
+	github.com/google/go-cmp v0.6.0
 	golang.org/x/mod v0.10.0 // indirect
-	golang.org/x/sys v0.7.0 // indirect
+	golang.org/x/sys v0.22.0
 	golang.org/x/tools v0.8.0
 	honnef.co/go/tools v0.4.3
 )
 
-require golang.org/x/vuln v0.0.0-20230407211851-ee3d87385065
+require (
+	github.com/prometheus/client_golang v1.20.5
+	golang.org/x/vuln v0.0.0-20230407211851-ee3d87385065
+)
 
 require (
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/kylelemons/godebug v1.1.0 // indirect
+	github.com/munnerz/goautoneg v0.0.0-20191010083416-a7dc8b61c822 // indirect
+	github.com/prometheus/client_model v0.6.1 // indirect
+	github.com/prometheus/common v0.55.0 // indirect
+	github.com/prometheus/procfs v0.15.1 // indirect
 	golang.org/x/exp/typeparams v0.0.0-20230321023759-10a507213a29 // indirect
-	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sync v0.7.0 // indirect
+	google.golang.org/protobuf v1.34.2 // indirect
 )
@@ -0,0 +1,70 @@
+package sysctl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CanonicalKey returns the canonical form of a sysctl key, in which
+// components are separated by dots.
+//
+// As in procps, keys can also be written with components separated by
+// slashes, in which case dots are part of the component name, as in
+// net/ipv4/conf/eth0.100/rp_filter. The canonical form of such keys swaps
+// dots and slashes, so that dots in component names are represented as
+// slashes, e.g. net.ipv4.conf.eth0/100.rp_filter.
+// Keys returned by this package are always in canonical form.
+func CanonicalKey(key string) string {
+	return pathToKey(keyToPath(key))
+}
+
+// keyToPath returns the path, relative to the sysctl root,
+// of the file corresponding to a key
+func keyToPath(key string) string {
+	i := strings.IndexAny(key, "./")
+	if i < 0 || key[i] == '/' {
+		// already separated by slashes
+		return key
+	}
+	return swapSeparators(key)
+}
+
+// pathToKey returns the canonical key corresponding to a path
+// relative to the sysctl root
+func pathToKey(path string) string {
+	return swapSeparators(path)
+}
+
+// swapSeparators replaces dots with slashes and vice versa
+func swapSeparators(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.':
+			return '/'
+		case '/':
+			return '.'
+		}
+		return r
+	}, s)
+}
+
+// validateKey checks that a key is well formed and that it cannot refer
+// to a file outside of the sysctl root
+func validateKey(key string) error {
+	if strings.ContainsRune(key, 0) {
+		return fmt.Errorf("%w: key contains NUL byte", ErrInvalidKey)
+	}
+	path := keyToPath(key)
+	if strings.HasPrefix(path, "/") {
+		return fmt.Errorf("%w: absolute path", ErrInvalidKey)
+	}
+	for _, c := range strings.Split(path, "/") {
+		switch c {
+		case "":
+			return fmt.Errorf("%w: empty component", ErrInvalidKey)
+		case ".", "..":
+			return fmt.Errorf("%w: relative path component %q", ErrInvalidKey, c)
+		}
+	}
+	return nil
+}
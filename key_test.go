@@ -0,0 +1,126 @@
+package sysctl
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonicalKey(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "net.ipv4.ip_forward",
+			expected: "net.ipv4.ip_forward",
+		},
+		{
+			in:       "net/ipv4/ip_forward",
+			expected: "net.ipv4.ip_forward",
+		},
+		{
+			in:       "net/ipv4/conf/eth0.100/rp_filter",
+			expected: "net.ipv4.conf.eth0/100.rp_filter",
+		},
+		{
+			in:       "net.ipv4.conf.eth0/100.rp_filter",
+			expected: "net.ipv4.conf.eth0/100.rp_filter",
+		},
+		{
+			in:       "f",
+			expected: "f",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			if got := CanonicalKey(c.in); got != c.expected {
+				t.Fatalf("expected: %s. Got: %s", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestKeyToPath(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "net.ipv4.ip_forward",
+			expected: "net/ipv4/ip_forward",
+		},
+		{
+			in:       "net.ipv4.conf.eth0/100.rp_filter",
+			expected: "net/ipv4/conf/eth0.100/rp_filter",
+		},
+		{
+			in:       "net/ipv4/conf/eth0.100/rp_filter",
+			expected: "net/ipv4/conf/eth0.100/rp_filter",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			if got := keyToPath(c.in); got != c.expected {
+				t.Fatalf("expected: %s. Got: %s", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestPathToKey(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "net/ipv4/ip_forward",
+			expected: "net.ipv4.ip_forward",
+		},
+		{
+			in:       "net/ipv4/conf/eth0.100/rp_filter",
+			expected: "net.ipv4.conf.eth0/100.rp_filter",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			if got := pathToKey(c.in); got != c.expected {
+				t.Fatalf("expected: %s. Got: %s", c.expected, got)
+			}
+		})
+	}
+}
+
+func Test_validateKey(t *testing.T) {
+	cases := []struct {
+		key string
+		ok  bool
+	}{
+		{key: "net.ipv4.ip_forward", ok: true},
+		{key: "net/ipv4/ip_forward", ok: true},
+		{key: "net.ipv4.conf.eth0/100.rp_filter", ok: true},
+		{key: ""},
+		{key: "net..ipv4"},
+		{key: ".net.ipv4"},
+		{key: "net.ipv4."},
+		{key: "..etc.passwd"},
+		{key: "../../etc/passwd"},
+		{key: "net/../../etc/passwd"},
+		{key: "/etc/passwd"},
+		{key: "net/./ipv4"},
+		{key: "net.ipv4\x00.ip_forward"},
+	}
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			err := validateKey(c.key)
+			if c.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !c.ok && err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			if err != nil && !errors.Is(err, ErrInvalidKey) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
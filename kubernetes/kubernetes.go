@@ -0,0 +1,256 @@
+// Package kubernetes validates sysctls to be set in the security context
+// of Kubernetes pods, applying the same rules applied by the kubelet when
+// admitting pods.
+package kubernetes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+var (
+	// ErrInvalidName is returned for sysctl names or patterns that
+	// are not valid in a pod spec.
+	ErrInvalidName = errors.New("invalid sysctl name")
+	// ErrNotAllowed is returned for sysctls that are neither safe nor
+	// allowed as unsafe sysctls.
+	ErrNotAllowed = errors.New("sysctl not allowed")
+	// ErrHostNamespace is returned for sysctls scoped by a namespace
+	// that the pod shares with the host.
+	ErrHostNamespace = errors.New("sysctl not allowed in host namespace")
+	// ErrNotNamespaced is returned for unsafe sysctl patterns matching
+	// sysctls not known to be scoped by a namespace.
+	ErrNotNamespaced = errors.New("sysctl not known to be namespaced")
+)
+
+// maxNameLength is the maximum length of a sysctl name in a pod spec
+const maxNameLength = 253
+
+var (
+	// nameRegexp matches valid sysctl names
+	nameRegexp = regexp.MustCompile(`^([a-z0-9]([-_a-z0-9]*[a-z0-9])?[\./])*[a-z0-9]([-_a-z0-9]*[a-z0-9])?$`)
+	// patternRegexp matches valid sysctl names and patterns
+	// with a trailing "*"
+	patternRegexp = regexp.MustCompile(`^([a-z0-9]([-_a-z0-9]*[a-z0-9])?[\./])*([a-z0-9][-_a-z0-9]*)?[a-z0-9*]$`)
+)
+
+// safeSysctls are the sysctls considered safe by the kubelet
+var safeSysctls = []string{
+	"kernel.shm_rmid_forced",
+	"net.ipv4.ip_local_port_range",
+	"net.ipv4.tcp_syncookies",
+	"net.ipv4.ping_group_range",
+	"net.ipv4.ip_unprivileged_port_start",
+	"net.ipv4.ip_local_reserved_ports",
+	"net.ipv4.tcp_keepalive_time",
+	"net.ipv4.tcp_fin_timeout",
+	"net.ipv4.tcp_keepalive_intvl",
+	"net.ipv4.tcp_keepalive_probes",
+	"net.ipv4.tcp_rmem",
+	"net.ipv4.tcp_wmem",
+}
+
+// SafeSysctls returns the sysctls considered safe by the kubelet, which
+// pods can set without being explicitly allowed.
+// Some of them are only considered safe by recent kubelet versions
+// running on recent kernels.
+func SafeSysctls() []string {
+	return append([]string(nil), safeSysctls...)
+}
+
+// Sysctl is a sysctl to be set in a pod. It has the same fields and
+// JSON encoding as the Sysctl type of the Kubernetes core API.
+type Sysctl struct {
+	// Name is the name of the sysctl.
+	Name string `json:"name"`
+	// Value is the value of the sysctl.
+	Value string `json:"value"`
+}
+
+// SysctlError records an error occurred validating a sysctl.
+type SysctlError struct {
+	// Name is the sysctl name or pattern.
+	Name string
+	// Err is the underlying error, such as ErrNotAllowed.
+	Err error
+}
+
+func (e *SysctlError) Error() string {
+	return fmt.Sprintf("%q: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SysctlError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when validating sysctls that would cause
+// a pod to be rejected.
+type ValidationError struct {
+	// Errs are the errors occurred validating each rejected sysctl,
+	// of type *SysctlError.
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	errs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		errs[i] = err.Error()
+	}
+	return fmt.Sprintf("forbidden sysctls: %s", strings.Join(errs, "; "))
+}
+
+// Unwrap returns the errors occurred validating each rejected sysctl.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// ValidateOption is an option altering how sysctls are validated.
+type ValidateOption func(*validateOptions)
+
+type validateOptions struct {
+	hostNetwork bool
+	hostIPC     bool
+}
+
+// WithHostNetwork validates sysctls for a pod using the host network
+// namespace, in which case no sysctl scoped by the network namespace
+// is allowed.
+func WithHostNetwork() ValidateOption {
+	return func(o *validateOptions) {
+		o.hostNetwork = true
+	}
+}
+
+// WithHostIPC validates sysctls for a pod using the host IPC namespace,
+// in which case no sysctl scoped by the IPC namespace is allowed.
+func WithHostIPC() ValidateOption {
+	return func(o *validateOptions) {
+		o.hostIPC = true
+	}
+}
+
+// Validator validates the sysctls of pods against the safe sysctls and
+// a list of allowed unsafe sysctls, as configured on a kubelet.
+type Validator struct {
+	sysctls  map[string]sysctl.Namespace
+	prefixes map[string]sysctl.Namespace
+}
+
+// NewValidator returns a new Validator allowing the safe sysctls and
+// the given unsafe sysctls, as passed to the --allowed-unsafe-sysctls
+// flag of the kubelet. Unsafe sysctls can be either names or patterns
+// with a trailing "*", such as "net.core.*", and must only match sysctls
+// scoped by a namespace. Otherwise, an error of type *SysctlError is
+// returned.
+func NewValidator(allowedUnsafe []string) (*Validator, error) {
+	v := &Validator{
+		sysctls:  make(map[string]sysctl.Namespace),
+		prefixes: make(map[string]sysctl.Namespace),
+	}
+	for _, p := range append(SafeSysctls(), allowedUnsafe...) {
+		if len(p) > maxNameLength || !patternRegexp.MatchString(p) {
+			return nil, &SysctlError{Name: p, Err: ErrInvalidName}
+		}
+		p = sysctl.CanonicalKey(p)
+		prefix, isPattern := strings.CutSuffix(p, "*")
+		ns := namespaceOf(prefix)
+		if ns == sysctl.NamespaceNone {
+			return nil, &SysctlError{Name: p, Err: ErrNotNamespaced}
+		}
+		if isPattern {
+			v.prefixes[prefix] = ns
+		} else {
+			v.sysctls[p] = ns
+		}
+	}
+	return v, nil
+}
+
+// namespaceOf returns the namespace scoping the sysctls whose name is or,
+// for patterns, starts with a given prefix.
+// These are the same rules applied by the kubelet, which differ from
+// those of sysctl.NamespaceOf in that all kernel.shm* and kernel.msg*
+// sysctls are considered scoped by the IPC namespace, while UTS and user
+// namespaces are not considered.
+func namespaceOf(prefix string) sysctl.Namespace {
+	switch {
+	case strings.HasPrefix(prefix, "kernel.shm"),
+		strings.HasPrefix(prefix, "kernel.msg"),
+		strings.HasPrefix(prefix, "fs.mqueue."),
+		prefix == "kernel.sem":
+		return sysctl.NamespaceIPC
+	case strings.HasPrefix(prefix, "net."):
+		return sysctl.NamespaceNet
+	}
+	return sysctl.NamespaceNone
+}
+
+// Validate validates the sysctls to be set in a pod, such as those
+// returned by sysctl.LoadConfig, and returns the allowed ones sorted
+// by name, in the form in which they can be set in the pod spec.
+// If any sysctl would cause the pod to be rejected, the allowed sysctls
+// are returned along with an error of type *ValidationError, listing
+// all rejected sysctls.
+func (v *Validator) Validate(values map[string]string, opts ...ValidateOption) ([]Sysctl, error) {
+	var o validateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// names are sorted in canonical form, which is the form returned
+	names := make(map[string]string, len(values))
+	keys := make([]string, 0, len(values))
+	for name := range values {
+		key := sysctl.CanonicalKey(name)
+		names[key] = name
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var (
+		out  []Sysctl
+		verr ValidationError
+	)
+	for _, key := range keys {
+		name := names[key]
+		if err := v.validate(key, o); err != nil {
+			verr.Errs = append(verr.Errs, &SysctlError{Name: name, Err: err})
+			continue
+		}
+		out = append(out, Sysctl{Name: key, Value: values[name]})
+	}
+	if len(verr.Errs) > 0 {
+		return out, &verr
+	}
+	return out, nil
+}
+
+// validate checks whether a sysctl, whose name is in canonical form,
+// is allowed
+func (v *Validator) validate(name string, o validateOptions) error {
+	if len(name) > maxNameLength || !nameRegexp.MatchString(name) {
+		return ErrInvalidName
+	}
+	ns, ok := v.sysctls[name]
+	if !ok {
+		for p, pns := range v.prefixes {
+			if strings.HasPrefix(name, p) {
+				ns, ok = pns, true
+				break
+			}
+		}
+	}
+	switch {
+	case !ok:
+		return ErrNotAllowed
+	case ns == sysctl.NamespaceNet && o.hostNetwork:
+		return fmt.Errorf("%w: network namespace", ErrHostNamespace)
+	case ns == sysctl.NamespaceIPC && o.hostIPC:
+		return fmt.Errorf("%w: IPC namespace", ErrHostNamespace)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNewValidator(t *testing.T) {
+	cases := []struct {
+		name          string
+		allowedUnsafe []string
+		err           error
+	}{
+		{
+			name: "no unsafe sysctls",
+		},
+		{
+			name:          "names and patterns",
+			allowedUnsafe: []string{"kernel.msg*", "kernel.sem", "net.core.*", "net/ipv4/route/*"},
+		},
+		{
+			name:          "not namespaced",
+			allowedUnsafe: []string{"kernel.pid_max"},
+			err:           ErrNotNamespaced,
+		},
+		{
+			name:          "pattern not namespaced",
+			allowedUnsafe: []string{"kernel.*"},
+			err:           ErrNotNamespaced,
+		},
+		{
+			name:          "invalid pattern",
+			allowedUnsafe: []string{"net.*.rp_filter"},
+			err:           ErrInvalidName,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := NewValidator(c.allowedUnsafe)
+			if c.err == nil && err != nil {
+				t.Fatalf("could not create validator: %v", err)
+			}
+			if c.err != nil && !errors.Is(err, c.err) {
+				t.Fatalf("expected error matching %v, got: %v", c.err, err)
+			}
+		})
+	}
+}
+
+func TestValidatorValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		values   map[string]string
+		opts     []ValidateOption
+		expected []Sysctl
+		rejected map[string]error
+	}{
+		{
+			name: "safe and unsafe",
+			values: map[string]string{
+				"net.ipv4.tcp_syncookies":          "1",
+				"kernel.shm_rmid_forced":           "1",
+				"kernel.msgmax":                    "8192",
+				"net/core/somaxconn":               "1024",
+				"net/ipv4/conf/eth0.100/rp_filter": "2",
+			},
+			expected: []Sysctl{
+				{Name: "kernel.msgmax", Value: "8192"},
+				{Name: "kernel.shm_rmid_forced", Value: "1"},
+				{Name: "net.core.somaxconn", Value: "1024"},
+				{Name: "net.ipv4.conf.eth0/100.rp_filter", Value: "2"},
+				{Name: "net.ipv4.tcp_syncookies", Value: "1"},
+			},
+		},
+		{
+			name: "not allowed",
+			values: map[string]string{
+				"net.ipv4.tcp_syncookies": "1",
+				"net.ipv4.ip_forward":     "1",
+				"kernel.pid_max":          "4194304",
+			},
+			expected: []Sysctl{
+				{Name: "net.ipv4.tcp_syncookies", Value: "1"},
+			},
+			rejected: map[string]error{
+				"kernel.pid_max":      ErrNotAllowed,
+				"net.ipv4.ip_forward": ErrNotAllowed,
+			},
+		},
+		{
+			name: "invalid name",
+			values: map[string]string{
+				"net.ipv4.conf.*.rp_filter": "1",
+			},
+			rejected: map[string]error{
+				"net.ipv4.conf.*.rp_filter": ErrInvalidName,
+			},
+		},
+		{
+			name: "host network",
+			values: map[string]string{
+				"net.ipv4.tcp_syncookies": "1",
+				"kernel.shm_rmid_forced":  "1",
+			},
+			opts: []ValidateOption{WithHostNetwork()},
+			expected: []Sysctl{
+				{Name: "kernel.shm_rmid_forced", Value: "1"},
+			},
+			rejected: map[string]error{
+				"net.ipv4.tcp_syncookies": ErrHostNamespace,
+			},
+		},
+		{
+			name: "host IPC",
+			values: map[string]string{
+				"net.ipv4.tcp_syncookies": "1",
+				"kernel.shm_rmid_forced":  "1",
+				"kernel.msgmax":           "8192",
+			},
+			opts: []ValidateOption{WithHostIPC()},
+			expected: []Sysctl{
+				{Name: "net.ipv4.tcp_syncookies", Value: "1"},
+			},
+			rejected: map[string]error{
+				"kernel.msgmax":          ErrHostNamespace,
+				"kernel.shm_rmid_forced": ErrHostNamespace,
+			},
+		},
+	}
+	v, err := NewValidator([]string{"kernel.msg*", "net.core.*", "net.ipv4.conf.*"})
+	if err != nil {
+		t.Fatalf("could not create validator: %v", err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			out, err := v.Validate(c.values, c.opts...)
+			if diff := cmp.Diff(c.expected, out); diff != "" {
+				t.Fatalf("unexpected output (-want +got):\n%s", diff)
+			}
+			if len(c.rejected) == 0 {
+				if err != nil {
+					t.Fatalf("could not validate sysctls: %v", err)
+				}
+				return
+			}
+			t.Logf("err: %v", err)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if len(verr.Errs) != len(c.rejected) {
+				t.Fatalf("expected %d rejected sysctls, got %d", len(c.rejected), len(verr.Errs))
+			}
+			for _, err := range verr.Errs {
+				var serr *SysctlError
+				if !errors.As(err, &serr) {
+					t.Fatalf("unexpected error type: %T", err)
+				}
+				if expected := c.rejected[serr.Name]; !errors.Is(err, expected) {
+					t.Fatalf("expected error for %s matching %v, got: %v", serr.Name, expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSysctlJSON(t *testing.T) {
+	b, err := json.Marshal([]Sysctl{{Name: "net.ipv4.tcp_syncookies", Value: "1"}})
+	if err != nil {
+		t.Fatalf("could not marshal sysctls: %v", err)
+	}
+	expected := `[{"name":"net.ipv4.tcp_syncookies","value":"1"}]`
+	if string(b) != expected {
+		t.Fatalf("expected: %s. Got: %s", expected, b)
+	}
+}
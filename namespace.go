@@ -0,0 +1,87 @@
+package sysctl
+
+import "strings"
+
+// Namespace is a type of Linux namespace scoping a sysctl.
+type Namespace string
+
+// Namespace types scoping sysctls.
+const (
+	// NamespaceNone is returned for sysctls not scoped by any namespace,
+	// i.e. sysctls whose value applies to the whole host.
+	NamespaceNone Namespace = ""
+	// NamespaceNet is the network namespace.
+	NamespaceNet Namespace = "net"
+	// NamespaceIPC is the IPC namespace.
+	NamespaceIPC Namespace = "ipc"
+	// NamespaceUTS is the UTS namespace.
+	NamespaceUTS Namespace = "uts"
+	// NamespaceUser is the user namespace.
+	NamespaceUser Namespace = "user"
+)
+
+// namespacedKeys are the sysctls scoped by a namespace, other than
+// those matching namespacedPrefixes
+var namespacedKeys = map[string]Namespace{
+	"kernel.msgmax":          NamespaceIPC,
+	"kernel.msgmnb":          NamespaceIPC,
+	"kernel.msgmni":          NamespaceIPC,
+	"kernel.sem":             NamespaceIPC,
+	"kernel.shmall":          NamespaceIPC,
+	"kernel.shmmax":          NamespaceIPC,
+	"kernel.shmmni":          NamespaceIPC,
+	"kernel.shm_rmid_forced": NamespaceIPC,
+	"kernel.hostname":        NamespaceUTS,
+	"kernel.domainname":      NamespaceUTS,
+}
+
+// namespacedPrefixes are the prefixes of the keys of sysctls scoped
+// by a namespace
+var namespacedPrefixes = []struct {
+	prefix string
+	ns     Namespace
+}{
+	{"fs.mqueue.", NamespaceIPC},
+	{"net.", NamespaceNet},
+	{"user.", NamespaceUser},
+}
+
+// NamespaceOf returns the type of namespace scoping a sysctl, or
+// NamespaceNone if the sysctl applies to the whole host.
+//
+// Keys are classified as done by runc when validating the sysctls of
+// a container: all net.* keys are scoped by the network namespace,
+// the System V IPC and fs.mqueue.* keys by the IPC namespace and
+// kernel.hostname and kernel.domainname by the UTS namespace.
+// Additionally, user.* keys are scoped by the user namespace.
+// Keys containing glob patterns are classified as any other key, so a
+// pattern only matching namespaced keys, such as kernel.shm*, may still
+// be reported as not namespaced.
+func NamespaceOf(key string) Namespace {
+	key = CanonicalKey(key)
+	if ns, ok := namespacedKeys[key]; ok {
+		return ns
+	}
+	for _, p := range namespacedPrefixes {
+		if strings.HasPrefix(key, p.prefix) {
+			return p.ns
+		}
+	}
+	return NamespaceNone
+}
+
+// SplitNamespaced splits a map of sysctl values, such as one returned by
+// LoadConfig, into the values of sysctls scoped by a namespace and the
+// values of sysctls applying to the whole host, as reported by NamespaceOf.
+func SplitNamespaced(values map[string]string) (namespaced, global map[string]string) {
+	namespaced = make(map[string]string)
+	global = make(map[string]string)
+	for k, v := range values {
+		if NamespaceOf(k) == NamespaceNone {
+			global[k] = v
+		} else {
+			namespaced[k] = v
+		}
+	}
+	return namespaced, global
+}
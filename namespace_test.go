@@ -0,0 +1,100 @@
+package sysctl
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestNamespaceOf(t *testing.T) {
+	cases := []struct {
+		key      string
+		expected Namespace
+	}{
+		{
+			key:      "net.ipv4.ip_forward",
+			expected: NamespaceNet,
+		},
+		{
+			key:      "net/ipv4/conf/eth0.100/rp_filter",
+			expected: NamespaceNet,
+		},
+		{
+			key:      "net.ipv4.conf.*.rp_filter",
+			expected: NamespaceNet,
+		},
+		{
+			key:      "kernel.shmmax",
+			expected: NamespaceIPC,
+		},
+		{
+			key:      "kernel.sem",
+			expected: NamespaceIPC,
+		},
+		{
+			key:      "fs.mqueue.msg_max",
+			expected: NamespaceIPC,
+		},
+		{
+			key:      "kernel/hostname",
+			expected: NamespaceUTS,
+		},
+		{
+			key:      "kernel.domainname",
+			expected: NamespaceUTS,
+		},
+		{
+			key:      "user.max_user_namespaces",
+			expected: NamespaceUser,
+		},
+		{
+			key:      "kernel.pid_max",
+			expected: NamespaceNone,
+		},
+		{
+			key:      "kernel.semaphore",
+			expected: NamespaceNone,
+		},
+		{
+			key:      "fs.file-max",
+			expected: NamespaceNone,
+		},
+		{
+			key:      "network",
+			expected: NamespaceNone,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.key, func(t *testing.T) {
+			if got := NamespaceOf(c.key); got != c.expected {
+				t.Fatalf("expected: %q. Got: %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestSplitNamespaced(t *testing.T) {
+	values := map[string]string{
+		"net.ipv4.ip_forward": "1",
+		"kernel.shmmax":       "4096",
+		"kernel.hostname":     "example.com",
+		"kernel.pid_max":      "4194304",
+		"vm.swappiness":       "10",
+	}
+	namespaced, global := SplitNamespaced(values)
+	expectedNamespaced := map[string]string{
+		"net.ipv4.ip_forward": "1",
+		"kernel.shmmax":       "4096",
+		"kernel.hostname":     "example.com",
+	}
+	expectedGlobal := map[string]string{
+		"kernel.pid_max": "4194304",
+		"vm.swappiness":  "10",
+	}
+	if diff := cmp.Diff(expectedNamespaced, namespaced); diff != "" {
+		t.Fatalf("unexpected namespaced values (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff(expectedGlobal, global); diff != "" {
+		t.Fatalf("unexpected global values (-want +got):\n%s", diff)
+	}
+}
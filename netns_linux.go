@@ -0,0 +1,143 @@
+//go:build linux
+// +build linux
+
+package sysctl
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+var errClosed = errors.New("client is closed")
+
+// nsTarget is a namespace to join
+type nsTarget struct {
+	fd     int
+	nstype int
+}
+
+// nsThread runs functions on an OS thread that joined a set of namespaces
+type nsThread struct {
+	mu     sync.Mutex
+	closed bool
+	reqs   chan func()
+}
+
+func startNSThread(targets []nsTarget) (*nsThread, error) {
+	t := &nsThread{reqs: make(chan func())}
+	errc := make(chan error)
+	go func() {
+		// The thread is never unlocked, so that it is terminated when
+		// this goroutine returns instead of being reused by other
+		// goroutines, since it cannot be moved back to its original
+		// namespaces.
+		runtime.LockOSThread()
+		for _, ns := range targets {
+			if err := unix.Setns(ns.fd, ns.nstype); err != nil {
+				errc <- fmt.Errorf("could not join namespace: %w", err)
+				return
+			}
+		}
+		errc <- nil
+		for fn := range t.reqs {
+			fn()
+		}
+	}()
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *nsThread) run(fn func() error) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return errClosed
+	}
+	errc := make(chan error, 1)
+	t.reqs <- func() { errc <- fn() }
+	t.mu.Unlock()
+	return <-errc
+}
+
+func (t *nsThread) close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.closed {
+		t.closed = true
+		close(t.reqs)
+	}
+	return nil
+}
+
+// newNSClient returns a client whose file operations run on a thread
+// that joined the given namespaces
+func newNSClient(targets []nsTarget) (*Client, error) {
+	t, err := startNSThread(targets)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client: %w", err)
+	}
+	return &Client{path: DefaultPath, run: t.run, close: t.close}, nil
+}
+
+// NewNetNSClient returns a new Client reading and writing sysctls in the
+// network namespace referred to by nsPath, such as /var/run/netns/<name>
+// or /proc/<pid>/ns/net.
+// All file operations of the client run on a dedicated OS thread that
+// joined the namespace, which requires CAP_SYS_ADMIN.
+// The client must be closed with Close when no longer needed.
+func NewNetNSClient(nsPath string) (*Client, error) {
+	f, err := os.Open(nsPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not create client: %w", err)
+	}
+	defer f.Close()
+	return NewNetNSClientFromFd(int(f.Fd()))
+}
+
+// NewNetNSClientFromFd is like NewNetNSClient, but the network namespace
+// is referred to by an open file descriptor. The file descriptor is only
+// used while creating the client and can be closed afterwards.
+func NewNetNSClientFromFd(fd int) (*Client, error) {
+	return newNSClient([]nsTarget{{fd: fd, nstype: unix.CLONE_NEWNET}})
+}
+
+// nsTypes are the types of namespaces that a client can join
+var nsTypes = map[Namespace]int{
+	NamespaceNet: unix.CLONE_NEWNET,
+	NamespaceIPC: unix.CLONE_NEWIPC,
+	NamespaceUTS: unix.CLONE_NEWUTS,
+}
+
+// NewProcessClient returns a new Client reading and writing sysctls in the
+// namespaces of the process with the given pid, such as a container.
+// Only network, IPC and UTS namespaces can be joined and, if no namespace
+// is specified, all of them are joined.
+// As for NewNetNSClient, joining namespaces requires CAP_SYS_ADMIN and
+// the client must be closed with Close when no longer needed.
+func NewProcessClient(pid int, namespaces ...Namespace) (*Client, error) {
+	if len(namespaces) == 0 {
+		namespaces = []Namespace{NamespaceNet, NamespaceIPC, NamespaceUTS}
+	}
+	targets := make([]nsTarget, 0, len(namespaces))
+	for _, ns := range namespaces {
+		nstype, ok := nsTypes[ns]
+		if !ok {
+			return nil, fmt.Errorf("could not create client: unsupported namespace %q", ns)
+		}
+		f, err := os.Open("/proc/" + strconv.Itoa(pid) + "/ns/" + string(ns))
+		if err != nil {
+			return nil, fmt.Errorf("could not create client: %w", err)
+		}
+		defer f.Close()
+		targets = append(targets, nsTarget{fd: int(f.Fd()), nstype: nstype})
+	}
+	return newNSClient(targets)
+}
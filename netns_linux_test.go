@@ -0,0 +1,120 @@
+//go:build linux
+// +build linux
+
+package sysctl
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestNewNetNSClient(t *testing.T) {
+	if !isUserRoot() {
+		t.Skip("user not root, skipping test")
+	}
+	const key = "net.ipv4.ip_forward"
+	host, err := Get(key)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", key, err)
+	}
+
+	ns, err := newTestNetNS()
+	if err != nil {
+		t.Skipf("could not create network namespace: %v", err)
+	}
+	defer ns.Close()
+
+	cl, err := NewNetNSClientFromFd(int(ns.Fd()))
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	defer cl.Close()
+
+	val := "1"
+	if host == "1" {
+		val = "0"
+	}
+	if err := cl.Set(key, val); err != nil {
+		t.Fatalf("could not set %s: %v", key, err)
+	}
+	got, err := cl.Get(key)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", key, err)
+	}
+	if got != val {
+		t.Fatalf("got wrong value in namespace: expected %s, got %s", val, got)
+	}
+	got, err = Get(key)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", key, err)
+	}
+	if got != host {
+		t.Fatalf("value in host namespace changed: expected %s, got %s", host, got)
+	}
+
+	if err := cl.Close(); err != nil {
+		t.Fatalf("could not close client: %v", err)
+	}
+	if _, err := cl.Get(key); !errors.Is(err, errClosed) {
+		t.Fatalf("expected error getting from closed client, got: %v", err)
+	}
+}
+
+func TestNewNetNSClientNotFound(t *testing.T) {
+	if _, err := NewNetNSClient("/var/run/netns/not-found"); err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+}
+
+func TestNewProcessClient(t *testing.T) {
+	if !isUserRoot() {
+		t.Skip("user not root, skipping test")
+	}
+	const key = "kernel.domainname"
+	expected, err := Get(key)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", key, err)
+	}
+	cl, err := NewProcessClient(os.Getpid())
+	if err != nil {
+		t.Skipf("could not create client: %v", err)
+	}
+	defer cl.Close()
+	got, err := cl.Get(key)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", key, err)
+	}
+	if got != expected {
+		t.Fatalf("expected: %s. Got: %s", expected, got)
+	}
+	if _, err := NewProcessClient(os.Getpid(), NamespaceUser); err == nil {
+		t.Fatal("expected error joining user namespace but it succeeded")
+	}
+}
+
+// newTestNetNS creates a new network namespace and returns
+// a file referring to it
+func newTestNetNS() (*os.File, error) {
+	type result struct {
+		f   *os.File
+		err error
+	}
+	res := make(chan result)
+	go func() {
+		// the thread is not unlocked so that it is terminated
+		// after leaving the host namespace
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWNET); err != nil {
+			res <- result{err: err}
+			return
+		}
+		f, err := os.Open("/proc/thread-self/ns/net")
+		res <- result{f: f, err: err}
+	}()
+	r := <-res
+	return r.f, r.err
+}
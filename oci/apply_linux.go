@@ -0,0 +1,44 @@
+//go:build linux
+// +build linux
+
+package oci
+
+import (
+	"fmt"
+	"sort"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+// Apply validates the sysctls of a configuration with Validate and sets
+// them in the namespaces of the container process with the given pid,
+// in order of key. Options are applied as in sysctl.Client.ApplyConfig.
+// This requires CAP_SYS_ADMIN, as described in sysctl.NewProcessClient.
+func Apply(spec *Spec, pid int, opts ...sysctl.ApplyOption) error {
+	if err := Validate(spec); err != nil {
+		return err
+	}
+	sysctls := spec.Sysctls()
+	if len(sysctls) == 0 {
+		return nil
+	}
+	config := &sysctl.Config{Entries: make([]sysctl.Entry, 0, len(sysctls))}
+	namespaces := make(map[sysctl.Namespace]bool)
+	for k, v := range sysctls {
+		config.Entries = append(config.Entries, sysctl.Entry{Key: k, Value: v})
+		namespaces[sysctl.NamespaceOf(k)] = true
+	}
+	sort.Slice(config.Entries, func(i, j int) bool {
+		return config.Entries[i].Key < config.Entries[j].Key
+	})
+	join := make([]sysctl.Namespace, 0, len(namespaces))
+	for ns := range namespaces {
+		join = append(join, ns)
+	}
+	cl, err := sysctl.NewProcessClient(pid, join...)
+	if err != nil {
+		return fmt.Errorf("could not join namespaces of process %d: %w", pid, err)
+	}
+	defer cl.Close()
+	return cl.ApplyConfig(config, opts...)
+}
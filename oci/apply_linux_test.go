@@ -0,0 +1,81 @@
+//go:build linux
+// +build linux
+
+package oci
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+// newTestUTSNS returns the id of a thread in a new UTS namespace, which
+// can be used in place of a pid to refer to the namespace until the
+// returned function is called
+func newTestUTSNS(t *testing.T) (int, func()) {
+	t.Helper()
+	res := make(chan int)
+	errc := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		// the thread is not unlocked so that it is terminated
+		// after leaving the host namespace
+		runtime.LockOSThread()
+		if err := unix.Unshare(unix.CLONE_NEWUTS); err != nil {
+			errc <- err
+			return
+		}
+		res <- unix.Gettid()
+		<-done
+	}()
+	select {
+	case tid := <-res:
+		return tid, func() { close(done) }
+	case err := <-errc:
+		t.Fatalf("could not create UTS namespace: %v", err)
+		return 0, nil
+	}
+}
+
+func TestApply(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("user not root, skipping test")
+	}
+	const key = "kernel.domainname"
+	hostVal, err := sysctl.Get(key)
+	if err != nil {
+		t.Fatalf("could not get %s: %v", key, err)
+	}
+	tid, done := newTestUTSNS(t)
+	defer done()
+	spec := &Spec{
+		Linux: &Linux{
+			Sysctl:     map[string]string{key: "oci-test"},
+			Namespaces: []LinuxNamespace{{Type: UTSNamespace}},
+		},
+	}
+	if err := Apply(spec, tid); err != nil {
+		t.Fatalf("could not apply spec: %v", err)
+	}
+	cl, err := sysctl.NewProcessClient(tid, sysctl.NamespaceUTS)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	defer cl.Close()
+	if got, err := cl.Get(key); err != nil || got != "oci-test" {
+		t.Fatalf("unexpected value in namespace: %q, err: %v", got, err)
+	}
+	if got, err := sysctl.Get(key); err != nil || got != hostVal {
+		t.Fatalf("host value changed: %q, err: %v", got, err)
+	}
+	spec.Linux.Namespaces = nil
+	var verr *ValidationError
+	if err := Apply(spec, tid); !errors.As(err, &verr) {
+		t.Fatalf("expected validation error, got: %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+// Package oci reads the sysctls of containers from OCI runtime
+// configurations, validates them and applies them into the namespaces
+// of containers.
+package oci
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+var (
+	// ErrNotNamespaced is returned for sysctls not scoped by a namespace,
+	// which cannot be set for a container.
+	ErrNotNamespaced = errors.New("sysctl is not in a separate kernel namespace")
+	// ErrHostNamespace is returned for sysctls scoped by a namespace
+	// that the container shares with the host.
+	ErrHostNamespace = errors.New("sysctl is not allowed in the host namespace")
+	// ErrHostname is returned for kernel.hostname, which must be set
+	// with the hostname field of the configuration instead.
+	ErrHostname = errors.New("sysctl conflicts with the hostname field")
+)
+
+// Namespace types of the OCI runtime specification.
+const (
+	NetworkNamespace = "network"
+	IPCNamespace     = "ipc"
+	UTSNamespace     = "uts"
+)
+
+// Spec is the subset of the OCI runtime configuration, i.e. the content
+// of the config.json file of a bundle, relevant to sysctls.
+type Spec struct {
+	// Hostname is the hostname of the container.
+	Hostname string `json:"hostname,omitempty"`
+	// Linux is the Linux-specific configuration.
+	Linux *Linux `json:"linux,omitempty"`
+}
+
+// Linux is the subset of the Linux-specific configuration of a container
+// relevant to sysctls.
+type Linux struct {
+	// Sysctl are the sysctls to set for the container.
+	Sysctl map[string]string `json:"sysctl,omitempty"`
+	// Namespaces are the namespaces of the container.
+	Namespaces []LinuxNamespace `json:"namespaces,omitempty"`
+}
+
+// LinuxNamespace is a namespace of a container.
+type LinuxNamespace struct {
+	// Type is the namespace type, such as NetworkNamespace.
+	Type string `json:"type"`
+	// Path is the path of an existing namespace joined by the container.
+	// If empty, a new namespace is created.
+	Path string `json:"path,omitempty"`
+}
+
+// State is the state of a container, as passed to hooks on standard input.
+type State struct {
+	// Version is the version of the OCI runtime specification.
+	Version string `json:"ociVersion"`
+	// ID is the container ID.
+	ID string `json:"id"`
+	// Status is the runtime status of the container.
+	Status string `json:"status"`
+	// Pid is the ID of the container process, as seen by the host.
+	Pid int `json:"pid,omitempty"`
+	// Bundle is the absolute path of the bundle directory of the container.
+	Bundle string `json:"bundle"`
+	// Annotations are the annotations of the container.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// ReadSpec reads an OCI runtime configuration from a file,
+// such as the config.json file of a bundle.
+func ReadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read configuration: %w", err)
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("could not parse configuration %s: %w", path, err)
+	}
+	return &spec, nil
+}
+
+// ReadState reads the state of a container, such as the one passed
+// to hooks on standard input.
+func ReadState(r io.Reader) (*State, error) {
+	var state State
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, fmt.Errorf("could not parse state: %w", err)
+	}
+	return &state, nil
+}
+
+// Sysctls returns the sysctls of a configuration, with keys in
+// canonical form.
+func (s *Spec) Sysctls() map[string]string {
+	if s.Linux == nil {
+		return nil
+	}
+	out := make(map[string]string, len(s.Linux.Sysctl))
+	for k, v := range s.Linux.Sysctl {
+		out[sysctl.CanonicalKey(k)] = v
+	}
+	return out
+}
+
+// namespace returns the namespace of a given type of the container
+func (s *Spec) namespace(typ string) (LinuxNamespace, bool) {
+	if s.Linux == nil {
+		return LinuxNamespace{}, false
+	}
+	for _, ns := range s.Linux.Namespaces {
+		if ns.Type == typ {
+			return ns, true
+		}
+	}
+	return LinuxNamespace{}, false
+}
+
+// SysctlError records an error occurred validating a sysctl.
+type SysctlError struct {
+	// Key is the sysctl key.
+	Key string
+	// Err is the underlying error, such as ErrNotNamespaced.
+	Err error
+}
+
+func (e *SysctlError) Error() string {
+	return fmt.Sprintf("sysctl %q: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SysctlError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError is returned when validating a configuration
+// containing sysctls that cannot be set for the container.
+type ValidationError struct {
+	// Errs are the errors occurred validating each invalid sysctl,
+	// of type *SysctlError, sorted by key.
+	Errs []error
+}
+
+func (e *ValidationError) Error() string {
+	errs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		errs[i] = err.Error()
+	}
+	return fmt.Sprintf("invalid sysctls: %s", strings.Join(errs, "; "))
+}
+
+// Unwrap returns the errors occurred validating each invalid sysctl.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errs
+}
+
+// Validate checks that all sysctls of a configuration can be set for the
+// container, applying the same rules applied by runc.
+// Sysctls must be scoped by a network, IPC or UTS namespace, as reported by
+// sysctl.NamespaceOf, and the container must have its own namespace of that
+// type. A network namespace joined by path must not be the network namespace
+// of the caller, which is assumed to be the host one.
+// kernel.hostname is rejected, since the hostname field must be used instead.
+// If any sysctl is invalid, an error of type *ValidationError is returned.
+func Validate(spec *Spec) error {
+	sysctls := spec.Sysctls()
+	keys := make([]string, 0, len(sysctls))
+	for k := range sysctls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var verr ValidationError
+	for _, k := range keys {
+		if err := validate(spec, k); err != nil {
+			verr.Errs = append(verr.Errs, &SysctlError{Key: k, Err: err})
+		}
+	}
+	if len(verr.Errs) > 0 {
+		return &verr
+	}
+	return nil
+}
+
+// validate checks whether a sysctl, whose key is in canonical form,
+// can be set for a container
+func validate(spec *Spec, key string) error {
+	var typ string
+	switch sysctl.NamespaceOf(key) {
+	case sysctl.NamespaceNet:
+		typ = NetworkNamespace
+	case sysctl.NamespaceIPC:
+		typ = IPCNamespace
+	case sysctl.NamespaceUTS:
+		if key == "kernel.hostname" {
+			return ErrHostname
+		}
+		typ = UTSNamespace
+	default:
+		return ErrNotNamespaced
+	}
+	ns, ok := spec.namespace(typ)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrHostNamespace, typ)
+	}
+	if typ == NetworkNamespace && ns.Path != "" {
+		host, err := isHostNamespace(ns)
+		if err != nil {
+			return err
+		}
+		if host {
+			return fmt.Errorf("%w: %s", ErrHostNamespace, typ)
+		}
+	}
+	return nil
+}
+
+// isHostNamespace returns whether a namespace joined by path is the
+// namespace of the same type of the caller
+func isHostNamespace(ns LinuxNamespace) (bool, error) {
+	name := ns.Type
+	if name == NetworkNamespace {
+		name = "net"
+	}
+	host, err := os.Stat("/proc/self/ns/" + name)
+	if err != nil {
+		return false, fmt.Errorf("could not get host namespace: %w", err)
+	}
+	info, err := os.Stat(ns.Path)
+	if err != nil {
+		return false, fmt.Errorf("could not get namespace: %w", err)
+	}
+	return os.SameFile(host, info), nil
+}
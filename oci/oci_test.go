@@ -0,0 +1,152 @@
+package oci
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReadSpec(t *testing.T) {
+	spec, err := ReadSpec("../testdata/oci/config.json")
+	if err != nil {
+		t.Fatalf("could not read spec: %v", err)
+	}
+	expected := map[string]string{
+		"net.ipv4.ip_forward":              "1",
+		"net.ipv4.conf.eth0/100.rp_filter": "2",
+		"kernel.shmmax":                    "4096",
+	}
+	if diff := cmp.Diff(expected, spec.Sysctls()); diff != "" {
+		t.Fatalf("unexpected sysctls (-want +got):\n%s", diff)
+	}
+	if err := Validate(spec); err != nil {
+		t.Fatalf("could not validate spec: %v", err)
+	}
+	if _, err := ReadSpec("../testdata/oci/not-found.json"); err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+}
+
+func TestReadState(t *testing.T) {
+	in := `{"ociVersion": "1.0.2", "id": "abc", "status": "creating", "pid": 1234, "bundle": "/run/bundle"}`
+	state, err := ReadState(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("could not read state: %v", err)
+	}
+	expected := &State{Version: "1.0.2", ID: "abc", Status: "creating", Pid: 1234, Bundle: "/run/bundle"}
+	if diff := cmp.Diff(expected, state); diff != "" {
+		t.Fatalf("unexpected state (-want +got):\n%s", diff)
+	}
+	if _, err := ReadState(strings.NewReader("{")); err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name       string
+		sysctl     map[string]string
+		namespaces []LinuxNamespace
+		invalid    map[string]error
+	}{
+		{
+			name: "no sysctls",
+		},
+		{
+			name: "namespaced",
+			sysctl: map[string]string{
+				"net.ipv4.ip_forward": "1",
+				"kernel.msgmax":       "8192",
+				"fs.mqueue.msg_max":   "10",
+				"kernel.domainname":   "example.com",
+			},
+			namespaces: []LinuxNamespace{
+				{Type: NetworkNamespace},
+				{Type: IPCNamespace},
+				{Type: UTSNamespace},
+			},
+		},
+		{
+			name: "host namespaces",
+			sysctl: map[string]string{
+				"net.ipv4.ip_forward": "1",
+				"kernel.msgmax":       "8192",
+				"kernel.domainname":   "example.com",
+			},
+			invalid: map[string]error{
+				"net.ipv4.ip_forward": ErrHostNamespace,
+				"kernel.msgmax":       ErrHostNamespace,
+				"kernel.domainname":   ErrHostNamespace,
+			},
+		},
+		{
+			name: "host network namespace path",
+			sysctl: map[string]string{
+				"net.ipv4.ip_forward": "1",
+			},
+			namespaces: []LinuxNamespace{
+				{Type: NetworkNamespace, Path: "/proc/self/ns/net"},
+			},
+			invalid: map[string]error{
+				"net.ipv4.ip_forward": ErrHostNamespace,
+			},
+		},
+		{
+			name: "not namespaced",
+			sysctl: map[string]string{
+				"kernel.pid_max":           "4194304",
+				"user.max_user_namespaces": "0",
+			},
+			namespaces: []LinuxNamespace{
+				{Type: "user"},
+			},
+			invalid: map[string]error{
+				"kernel.pid_max":           ErrNotNamespaced,
+				"user.max_user_namespaces": ErrNotNamespaced,
+			},
+		},
+		{
+			name: "hostname",
+			sysctl: map[string]string{
+				"kernel/hostname": "example",
+			},
+			namespaces: []LinuxNamespace{
+				{Type: UTSNamespace},
+			},
+			invalid: map[string]error{
+				"kernel.hostname": ErrHostname,
+			},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			spec := &Spec{Linux: &Linux{Sysctl: c.sysctl, Namespaces: c.namespaces}}
+			err := Validate(spec)
+			if len(c.invalid) == 0 {
+				if err != nil {
+					t.Fatalf("could not validate spec: %v", err)
+				}
+				return
+			}
+			t.Logf("err: %v", err)
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("unexpected error type: %T", err)
+			}
+			if len(verr.Errs) != len(c.invalid) {
+				t.Fatalf("expected %d invalid sysctls, got %d", len(c.invalid), len(verr.Errs))
+			}
+			for _, err := range verr.Errs {
+				var serr *SysctlError
+				if !errors.As(err, &serr) {
+					t.Fatalf("unexpected error type: %T", err)
+				}
+				if expected := c.invalid[serr.Key]; !errors.Is(err, expected) {
+					t.Fatalf("expected error for %s matching %v, got: %v", serr.Key, expected, err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package sysctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+// Change is a change to the value of a sysctl required to apply
+// a configuration.
+type Change struct {
+	// Key is the sysctl key.
+	Key string
+	// Current is the current value of the sysctl.
+	// It is empty if the sysctl is missing or cannot be read.
+	Current string
+	// Desired is the value of the sysctl after applying the change.
+	Desired string
+	// Entry is the configuration entry the desired value comes from.
+	Entry Entry
+	// Missing is true if the sysctl does not exist.
+	Missing bool
+	// ReadOnly is true if the sysctl exists but cannot be written.
+	ReadOnly bool
+}
+
+// String returns a human-readable description of the change.
+func (c Change) String() string {
+	var state string
+	switch {
+	case c.Missing:
+		state = " (missing)"
+	case c.ReadOnly:
+		state = " (read-only)"
+	}
+	return fmt.Sprintf("%s: %q -> %q%s [%s]", c.Key, c.Current, c.Desired, state, c.Entry.Location())
+}
+
+// Plan is the list of changes required to apply a configuration,
+// in the order in which they are applied.
+type Plan struct {
+	Changes []Change
+}
+
+// String returns a human-readable description of the plan,
+// with one change per line.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, c := range p.Changes {
+		b.WriteString(c.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// normalizeValue returns a sysctl value with all sequences of whitespace
+// characters replaced by a single space, so that values can be compared
+// regardless of how they are formatted.
+func normalizeValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// PlanConfig returns the changes required to apply a configuration without
+// writing anything. Sysctls whose current value already matches
+// the configuration are not included.
+// Values are compared regardless of differences in whitespace.
+func (c *Client) PlanConfig(config *Config) (*Plan, error) {
+	entries, err := c.resolve(context.Background(), config)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{}
+	for _, e := range entries {
+		change := Change{Key: e.Key, Desired: e.Value, Entry: e}
+		mode, err := c.mode(e.Key)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			change.Missing = true
+		case err != nil:
+			return nil, &KeyError{Key: e.Key, Op: "get", Err: err}
+		default:
+			change.ReadOnly = mode.Perm()&0o222 == 0
+			// Write-only sysctls cannot be read, so they always
+			// need to be written
+			if current, err := c.Get(e.Key); err == nil {
+				if normalizeValue(current) == normalizeValue(e.Value) {
+					continue
+				}
+				change.Current = current
+			}
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+// ApplyPlan applies the changes of a plan, in order.
+// Failures to set keys prefixed with "-" in the configuration are ignored.
+func (c *Client) ApplyPlan(plan *Plan, opts ...ApplyOption) error {
+	return c.ApplyPlanContext(context.Background(), plan, opts...)
+}
+
+// ApplyPlanContext is like ApplyPlan, but it checks ctx between sysctls,
+// as ApplyConfigContext does.
+func (c *Client) ApplyPlanContext(ctx context.Context, plan *Plan, opts ...ApplyOption) error {
+	entries := make([]Entry, len(plan.Changes))
+	for i, ch := range plan.Changes {
+		entries[i] = ch.Entry
+		entries[i].Key = ch.Key
+		entries[i].Value = ch.Desired
+	}
+	return c.apply(ctx, entries, opts)
+}
+
+// mode returns the file mode of a sysctl
+func (c *Client) mode(key string) (fs.FileMode, error) {
+	if c.backend != nil {
+		if err := validateKey(key); err != nil {
+			return 0, err
+		}
+		return c.backend.Mode(CanonicalKey(key))
+	}
+	var mode fs.FileMode
+	err := c.do(func() error {
+		root, name, err := c.openKey(key)
+		if err != nil {
+			return err
+		}
+		defer root.Close()
+		info, err := root.Stat(name)
+		if err != nil {
+			return err
+		}
+		mode = info.Mode()
+		return nil
+	})
+	return mode, err
+}
@@ -0,0 +1,92 @@
+package sysctl
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClientPlanConfig(t *testing.T) {
+	path := t.TempDir()
+	createConfigFiles(t, path, map[string]string{
+		"a":   "1",
+		"b":   "4096\t131072",
+		"c":   "x",
+		"d/f": "old",
+	})
+	if err := os.Chmod(filepath.Join(path, "c"), 0o444); err != nil {
+		t.Fatalf("could not make file read-only: %v", err)
+	}
+	config := &Config{
+		Entries: []Entry{
+			{Key: "a", Value: "1", File: "test.conf", Line: 1},
+			{Key: "b", Value: "4096 131072", File: "test.conf", Line: 2},
+			{Key: "c", Value: "y", File: "test.conf", Line: 3},
+			{Key: "missing", Value: "1", File: "test.conf", Line: 4, IgnoreFailure: true},
+			{Key: "d.f", Value: "new", File: "test.conf", Line: 5},
+		},
+	}
+
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	plan, err := cl.PlanConfig(config)
+	if err != nil {
+		t.Fatalf("could not plan config: %v", err)
+	}
+	expected := &Plan{
+		Changes: []Change{
+			{Key: "c", Current: "x", Desired: "y", Entry: config.Entries[2], ReadOnly: true},
+			{Key: "missing", Desired: "1", Entry: config.Entries[3], Missing: true},
+			{Key: "d.f", Current: "old", Desired: "new", Entry: config.Entries[4]},
+		},
+	}
+	if diff := cmp.Diff(expected, plan); diff != "" {
+		t.Fatalf("unexpected plan (-want +got):\n%s", diff)
+	}
+	t.Logf("plan:\n%s", plan)
+
+	// Only apply changes to writable keys, since root can write
+	// read-only files in a regular directory
+	plan.Changes = plan.Changes[1:]
+	if err := cl.ApplyPlan(plan); err != nil {
+		t.Fatalf("could not apply plan: %v", err)
+	}
+	got, err := cl.Get("d.f")
+	if err != nil {
+		t.Fatalf("could not get key d.f: %v", err)
+	}
+	if got != "new" {
+		t.Fatalf("got wrong value for key d.f: expected: new, got %s", got)
+	}
+}
+
+func Test_normalizeValue(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{
+			in:       "1",
+			expected: "1",
+		},
+		{
+			in:       "4096\t131072\t6291456",
+			expected: "4096 131072 6291456",
+		},
+		{
+			in:       "  32768    60999 \n",
+			expected: "32768 60999",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.in, func(t *testing.T) {
+			if got := normalizeValue(c.in); got != c.expected {
+				t.Fatalf("expected: %q. Got: %q", c.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package prometheus provides a Prometheus collector exporting the values
+// of sysctls as metrics.
+package prometheus
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultNamespace is the default prefix of the names of all metrics.
+const DefaultNamespace = "sysctl"
+
+// invalidChars matches characters not allowed in metric names
+var invalidChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Option is an option of a Collector.
+type Option func(*Collector) error
+
+// WithKeys exports the given sysctls. Sysctls that do not exist, such as
+// those of kernel modules not loaded, are skipped.
+func WithKeys(keys ...string) Option {
+	return func(c *Collector) error {
+		for _, k := range keys {
+			c.keys = append(c.keys, sysctl.CanonicalKey(k))
+		}
+		return nil
+	}
+}
+
+// WithPattern exports all sysctls matching a pattern, with the same
+// syntax as sysctl.Client.GetPattern.
+func WithPattern(pattern string) Option {
+	return func(c *Collector) error {
+		if _, err := regexp.CompilePOSIX(pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		c.patterns = append(c.patterns, pattern)
+		return nil
+	}
+}
+
+// WithNamespace sets the prefix of the names of all metrics,
+// which is DefaultNamespace by default.
+func WithNamespace(namespace string) Option {
+	return func(c *Collector) error {
+		c.namespace = namespace
+		return nil
+	}
+}
+
+// Collector is a prometheus.Collector exporting the values of sysctls.
+//
+// Each sysctl is exported as a gauge named after its key, such as
+// sysctl_kernel_pid_max for kernel.pid_max. Sysctls whose value is made
+// of multiple numbers, such as fs.file-nr, are exported as one gauge with
+// an index label for each number, starting from 0. Sysctls whose value is
+// not numeric are exported as a gauge named after the key with an _info
+// suffix, with value 1 and the value of the sysctl in a value label.
+//
+// Since the type of each metric depends on the values read, the collector
+// is unchecked, i.e. it does not describe its metrics in advance.
+type Collector struct {
+	cl        *sysctl.Client
+	keys      []string
+	patterns  []string
+	namespace string
+}
+
+var _ prom.Collector = &Collector{}
+
+// NewCollector returns a new Collector exporting the sysctls read with a
+// client and selected by the given options. At least one of WithKeys and
+// WithPattern must be passed.
+func NewCollector(cl *sysctl.Client, opts ...Option) (*Collector, error) {
+	c := &Collector{cl: cl, namespace: DefaultNamespace}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, fmt.Errorf("could not create collector: %w", err)
+		}
+	}
+	if len(c.keys) == 0 && len(c.patterns) == 0 {
+		return nil, errors.New("could not create collector: no sysctls selected")
+	}
+	return c, nil
+}
+
+// Describe implements prometheus.Collector. It sends no descriptor,
+// making the collector unchecked.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	values := make(map[string]string)
+	for _, k := range c.keys {
+		v, err := c.cl.Get(k)
+		if err != nil {
+			if !errors.Is(err, sysctl.ErrNotExist) {
+				ch <- prom.NewInvalidMetric(c.desc(k, "", nil), err)
+			}
+			continue
+		}
+		values[k] = v
+	}
+	for _, p := range c.patterns {
+		vals, err := c.cl.GetPattern(p)
+		if err != nil {
+			ch <- prom.NewInvalidMetric(prom.NewDesc(c.namespace, "", nil, nil), err)
+			continue
+		}
+		for k, v := range vals {
+			values[k] = v
+		}
+	}
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		c.collect(ch, k, values[k])
+	}
+}
+
+// collect sends the metrics of a sysctl
+func (c *Collector) collect(ch chan<- prom.Metric, key, value string) {
+	fields := strings.Fields(value)
+	nums := make([]float64, len(fields))
+	for i, f := range fields {
+		n, ok := parseNumber(f)
+		if !ok {
+			nums = nil
+			break
+		}
+		nums[i] = n
+	}
+	help := fmt.Sprintf("Value of sysctl %s.", key)
+	switch {
+	case len(nums) == 1:
+		ch <- prom.MustNewConstMetric(c.desc(key, help, nil), prom.GaugeValue, nums[0])
+	case len(nums) > 1:
+		desc := c.desc(key, help, []string{"index"})
+		for i, n := range nums {
+			ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, n, strconv.Itoa(i))
+		}
+	default:
+		desc := c.desc(key+"_info", help, []string{"value"})
+		ch <- prom.MustNewConstMetric(desc, prom.GaugeValue, 1, strings.Join(fields, " "))
+	}
+}
+
+// desc returns the descriptor of the metric of a sysctl
+func (c *Collector) desc(key, help string, labels []string) *prom.Desc {
+	name := invalidChars.ReplaceAllString(key, "_")
+	return prom.NewDesc(prom.BuildFQName(c.namespace, "", name), help, labels, nil)
+}
+
+// parseNumber parses a decimal integer, which may not fit in an int64
+func parseNumber(s string) (float64, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return float64(n), true
+	}
+	if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+		return float64(n), true
+	}
+	return 0, false
+}
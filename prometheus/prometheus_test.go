@@ -0,0 +1,95 @@
+package prometheus
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []Option
+		expected string
+	}{
+		{
+			name: "keys",
+			opts: []Option{WithKeys("kernel.pid_max", "fs/file-nr", "net.netfilter.nf_conntrack_count")},
+			expected: `
+# HELP sysctl_fs_file_nr Value of sysctl fs.file-nr.
+# TYPE sysctl_fs_file_nr gauge
+sysctl_fs_file_nr{index="0"} 1024
+sysctl_fs_file_nr{index="1"} 0
+sysctl_fs_file_nr{index="2"} 9.223372036854776e+18
+# HELP sysctl_kernel_pid_max Value of sysctl kernel.pid_max.
+# TYPE sysctl_kernel_pid_max gauge
+sysctl_kernel_pid_max 4.194304e+06
+`,
+		},
+		{
+			name: "pattern",
+			opts: []Option{WithPattern(`^net\.ipv4\.`), WithNamespace("host_sysctl")},
+			expected: `
+# HELP host_sysctl_net_ipv4_conf_eth0_100_rp_filter Value of sysctl net.ipv4.conf.eth0/100.rp_filter.
+# TYPE host_sysctl_net_ipv4_conf_eth0_100_rp_filter gauge
+host_sysctl_net_ipv4_conf_eth0_100_rp_filter 2
+# HELP host_sysctl_net_ipv4_tcp_congestion_control_info Value of sysctl net.ipv4.tcp_congestion_control.
+# TYPE host_sysctl_net_ipv4_tcp_congestion_control_info gauge
+host_sysctl_net_ipv4_tcp_congestion_control_info{value="cubic"} 1
+# HELP host_sysctl_net_ipv4_tcp_mem Value of sysctl net.ipv4.tcp_mem.
+# TYPE host_sysctl_net_ipv4_tcp_mem gauge
+host_sysctl_net_ipv4_tcp_mem{index="0"} 94011
+host_sysctl_net_ipv4_tcp_mem{index="1"} 125351
+host_sysctl_net_ipv4_tcp_mem{index="2"} 188022
+`,
+		},
+	}
+	path := t.TempDir()
+	for name, content := range map[string]string{
+		"kernel/pid_max":                   "4194304",
+		"fs/file-nr":                       "1024\t0\t9223372036854775807",
+		"net/ipv4/tcp_mem":                 "94011\t125351\t188022",
+		"net/ipv4/tcp_congestion_control":  "cubic",
+		"net/ipv4/conf/eth0.100/rp_filter": "2",
+	} {
+		p := filepath.Join(path, name)
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatalf("could not create dir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatalf("could not create file %s: %v", p, err)
+		}
+	}
+	cl, err := sysctl.NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			col, err := NewCollector(cl, c.opts...)
+			if err != nil {
+				t.Fatalf("could not create collector: %v", err)
+			}
+			if err := testutil.CollectAndCompare(col, strings.NewReader(c.expected)); err != nil {
+				t.Fatalf("unexpected metrics: %v", err)
+			}
+		})
+	}
+}
+
+func TestNewCollectorInvalid(t *testing.T) {
+	cl, err := sysctl.NewClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	if _, err := NewCollector(cl); err == nil {
+		t.Fatal("expected error with no sysctls selected but it succeeded")
+	}
+	if _, err := NewCollector(cl, WithPattern("(")); err == nil {
+		t.Fatal("expected error with invalid pattern but it succeeded")
+	}
+}
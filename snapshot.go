@@ -0,0 +1,174 @@
+package sysctl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion is the version of the snapshot file format
+// written by this package.
+const SnapshotVersion = 1
+
+// volatileKeys are writable sysctls excluded from snapshots because their
+// value changes continuously or writing them triggers an action instead of
+// changing a setting
+var volatileKeys = map[string]bool{
+	"kernel.ns_last_pid":          true,
+	"kernel.random.uuid":          true,
+	"kernel.tainted":              true,
+	"net.ipv4.route.flush":        true,
+	"net.ipv6.route.flush":        true,
+	"vm.compact_memory":           true,
+	"vm.drop_caches":              true,
+	"vm.stat_refresh":             true,
+	"fs.file-nr":                  true,
+	"fs.inode-nr":                 true,
+	"fs.inode-state":              true,
+	"fs.dentry-state":             true,
+	"kernel.random.boot_id":       true,
+	"kernel.random.entropy_avail": true,
+}
+
+// volatilePrefixes are the prefixes of the keys of volatile sysctls
+var volatilePrefixes = []string{
+	"fs.binfmt_misc.",
+	"fs.quota.",
+}
+
+// isVolatile returns whether a sysctl is excluded from snapshots
+func isVolatile(key string) bool {
+	if volatileKeys[key] {
+		return true
+	}
+	for _, p := range volatilePrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Snapshot is the state of all writable sysctls of a host at a point
+// in time, which can be saved to a file and restored later.
+type Snapshot struct {
+	// Version is the version of the snapshot format.
+	Version int `json:"version"`
+	// KernelRelease is the value of kernel.osrelease.
+	KernelRelease string `json:"kernelRelease,omitempty"`
+	// Hostname is the value of kernel.hostname.
+	Hostname string `json:"hostname,omitempty"`
+	// Time is the time at which the snapshot was taken.
+	Time time.Time `json:"time"`
+	// Values are the values of the sysctls, keyed by sysctl key.
+	Values map[string]string `json:"values"`
+}
+
+// TakeSnapshot returns the current values of all readable and writable
+// sysctls, excluding sysctls whose value changes continuously, such as
+// kernel.random.uuid, or writing which triggers an action, such as
+// vm.drop_caches.
+func (c *Client) TakeSnapshot() (*Snapshot, error) {
+	all, err := c.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("could not get sysctls: %w", err)
+	}
+	s := &Snapshot{
+		Version: SnapshotVersion,
+		Time:    time.Now().UTC(),
+		Values:  make(map[string]string, len(all)),
+	}
+	if s.KernelRelease, err = c.getOptional("kernel.osrelease"); err != nil {
+		return nil, err
+	}
+	if s.Hostname, err = c.getOptional("kernel.hostname"); err != nil {
+		return nil, err
+	}
+	for k, v := range all {
+		if isVolatile(k) {
+			continue
+		}
+		mode, err := c.mode(k)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// removed after being read
+				continue
+			}
+			return nil, &KeyError{Key: k, Op: "get", Err: err}
+		}
+		if mode.Perm()&0o222 == 0 {
+			continue
+		}
+		s.Values[k] = v
+	}
+	return s, nil
+}
+
+// getOptional returns the value of a sysctl or an empty string
+// if the sysctl does not exist
+func (c *Client) getOptional(key string) (string, error) {
+	v, err := c.Get(key)
+	if errors.Is(err, ErrNotExist) {
+		return "", nil
+	}
+	return v, err
+}
+
+// Restore sets all sysctls to the values recorded in a snapshot.
+// Only sysctls whose current value differs from the one in the snapshot
+// are written. All sysctls are tried even if some of them cannot be set,
+// in which case an *ApplyError is returned listing them.
+// Other options are applied as in ApplyConfig.
+func (c *Client) Restore(s *Snapshot, opts ...ApplyOption) error {
+	plan, err := c.PlanConfig(s.Config())
+	if err != nil {
+		return err
+	}
+	return c.ApplyPlan(plan, append(opts, WithContinueOnError())...)
+}
+
+// Config returns the values of a snapshot as a configuration,
+// with entries sorted by key.
+func (s *Snapshot) Config() *Config {
+	config := &Config{Entries: make([]Entry, 0, len(s.Values))}
+	for k, v := range s.Values {
+		config.Entries = append(config.Entries, Entry{Key: k, Value: v})
+	}
+	sort.Slice(config.Entries, func(i, j int) bool {
+		return config.Entries[i].Key < config.Entries[j].Key
+	})
+	return config
+}
+
+// WriteFile writes a snapshot to a file in JSON format.
+func (s *Snapshot) WriteFile(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write snapshot: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot reads a snapshot from a file written by Snapshot.WriteFile.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read snapshot: %w", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("could not parse snapshot %s: %w", path, err)
+	}
+	if s.Version < 1 || s.Version > SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d in %s", s.Version, path)
+	}
+	return &s, nil
+}
@@ -0,0 +1,115 @@
+package sysctl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestClientSnapshotRestore(t *testing.T) {
+	path := t.TempDir()
+	createConfigFiles(t, path, map[string]string{
+		"kernel/osrelease":    "6.1.0",
+		"kernel/hostname":     "example",
+		"kernel/random/uuid":  "c2a8a5f4-2f4e-4b6a-9d0e-0d6a0f8f6d52",
+		"fs/file-nr":          "1024\t0\t9223372036854775807",
+		"net/ipv4/ip_forward": "0",
+		"net/ipv4/tcp_rmem":   "4096\t131072\t6291456",
+	})
+	for _, name := range []string{"kernel/osrelease", "fs/file-nr"} {
+		if err := os.Chmod(filepath.Join(path, name), 0o444); err != nil {
+			t.Fatalf("could not change mode of %s: %v", name, err)
+		}
+	}
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	s, err := cl.TakeSnapshot()
+	if err != nil {
+		t.Fatalf("could not take snapshot: %v", err)
+	}
+	if s.Version != SnapshotVersion || s.KernelRelease != "6.1.0" || s.Hostname != "example" || s.Time.IsZero() {
+		t.Fatalf("unexpected snapshot metadata: %+v", s)
+	}
+	expected := map[string]string{
+		"kernel.hostname":     "example",
+		"net.ipv4.ip_forward": "0",
+		"net.ipv4.tcp_rmem":   "4096\t131072\t6291456",
+	}
+	if diff := cmp.Diff(expected, s.Values); diff != "" {
+		t.Fatalf("unexpected snapshot values (-want +got):\n%s", diff)
+	}
+
+	file := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := s.WriteFile(file); err != nil {
+		t.Fatalf("could not write snapshot: %v", err)
+	}
+	read, err := ReadSnapshot(file)
+	if err != nil {
+		t.Fatalf("could not read snapshot: %v", err)
+	}
+	if diff := cmp.Diff(s, read); diff != "" {
+		t.Fatalf("unexpected snapshot read (-want +got):\n%s", diff)
+	}
+
+	createConfigFiles(t, path, map[string]string{
+		"net/ipv4/ip_forward": "1",
+		"net/ipv4/tcp_rmem":   "4096 131072 6291456",
+	})
+	read.Values["x.missing"] = "1"
+	err = cl.Restore(read)
+	if err == nil {
+		t.Fatal("expected error but it succeeded")
+	}
+	t.Logf("err: %v", err)
+	var aerr *ApplyError
+	if !errors.As(err, &aerr) {
+		t.Fatalf("unexpected error type: %T", err)
+	}
+	if diff := cmp.Diff([]string{"x.missing"}, aerr.Failed); diff != "" {
+		t.Fatalf("unexpected failed keys (-want +got):\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"net.ipv4.ip_forward"}, aerr.Applied); diff != "" {
+		t.Fatalf("unexpected applied keys (-want +got):\n%s", diff)
+	}
+	if got, _ := cl.Get("net.ipv4.tcp_rmem"); got != "4096 131072 6291456" {
+		t.Fatalf("value with whitespace differences unexpectedly written: %q", got)
+	}
+}
+
+func TestReadSnapshotInvalid(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "malformed",
+			content: "{",
+		},
+		{
+			name:    "no version",
+			content: `{"values": {}}`,
+		},
+		{
+			name:    "unsupported version",
+			content: `{"version": 1000, "values": {}}`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "snapshot.json")
+			if err := os.WriteFile(file, []byte(c.content), 0o644); err != nil {
+				t.Fatalf("could not write file: %v", err)
+			}
+			_, err := ReadSnapshot(file)
+			if err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			t.Logf("err: %v", err)
+		})
+	}
+}
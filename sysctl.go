@@ -1,6 +1,8 @@
 // Package sysctl provides functions wrapping the sysctl interface.
 package sysctl
 
+import "context"
+
 // DefaultPath is the default path to the sysctl virtual files.
 const DefaultPath = "/proc/sys/"
 
@@ -15,6 +17,12 @@ func Get(key string) (string, error) {
 	return std.Get(key)
 }
 
+// GetContext is like Get, but it fails without reading the sysctl
+// if ctx is done.
+func GetContext(ctx context.Context, key string) (string, error) {
+	return std.GetContext(ctx, key)
+}
+
 // GetPattern returns a map of sysctls matching a given pattern
 // The pattern uses a POSIX extended regular expression syntax.
 // This function matches the same sysctls that the command
@@ -23,20 +31,181 @@ func GetPattern(pattern string) (map[string]string, error) {
 	return std.GetPattern(pattern)
 }
 
+// GetPatternContext is like GetPattern, but it checks ctx between files.
+// If ctx is done before all sysctls are read, it stops and returns
+// a *CanceledError.
+func GetPatternContext(ctx context.Context, pattern string) (map[string]string, error) {
+	return std.GetPatternContext(ctx, pattern)
+}
+
+// WalkPattern calls fn for each sysctl matching a given pattern, as
+// matched by GetPattern, in the order in which the sysctls are listed.
+// Unlike GetPattern, sysctls that cannot be read are not skipped, but
+// passed to fn along with the error occurred reading them.
+func WalkPattern(pattern string, fn WalkFunc) error {
+	return std.WalkPattern(pattern, fn)
+}
+
+// WalkPatternContext is like WalkPattern, but it checks ctx between files.
+// If ctx is done before all sysctls are read, it stops and returns
+// a *CanceledError.
+func WalkPatternContext(ctx context.Context, pattern string, fn WalkFunc) error {
+	return std.WalkPatternContext(ctx, pattern, fn)
+}
+
 // GetAll returns all sysctls. This is equivalent
 // to running the command sysctl -a.
 func GetAll() (map[string]string, error) {
 	return std.GetAll()
 }
 
+// GetAllContext is like GetAll, but it checks ctx between files.
+// If ctx is done before all sysctls are read, it stops and returns
+// a *CanceledError.
+func GetAllContext(ctx context.Context) (map[string]string, error) {
+	return std.GetAllContext(ctx)
+}
+
 // Set updates the value of a sysctl.
 func Set(key, value string) error {
 	return std.Set(key, value)
 }
 
+// SetContext is like Set, but it fails without writing the sysctl
+// if ctx is done.
+func SetContext(ctx context.Context, key, value string) error {
+	return std.SetContext(ctx, key, value)
+}
+
+// GetInt returns the value of a sysctl as an int.
+// If the value cannot be converted, a *ValueError is returned.
+func GetInt(key string) (int, error) {
+	return std.GetInt(key)
+}
+
+// GetUint64 returns the value of a sysctl as an uint64.
+// If the value cannot be converted, a *ValueError is returned.
+func GetUint64(key string) (uint64, error) {
+	return std.GetUint64(key)
+}
+
+// GetBool returns the value of a sysctl as a bool.
+// The value must be either 0 or 1, otherwise a *ValueError is returned.
+func GetBool(key string) (bool, error) {
+	return std.GetBool(key)
+}
+
+// GetInts returns the value of a sysctl made of whitespace-separated
+// integers, such as net.ipv4.tcp_rmem, as a slice of ints.
+// If the value cannot be converted, a *ValueError is returned.
+func GetInts(key string) ([]int, error) {
+	return std.GetInts(key)
+}
+
+// SetInt updates the value of a sysctl to an int.
+func SetInt(key string, value int) error {
+	return std.SetInt(key, value)
+}
+
+// SetUint64 updates the value of a sysctl to an uint64.
+func SetUint64(key string, value uint64) error {
+	return std.SetUint64(key, value)
+}
+
+// SetBool updates the value of a sysctl to a bool, written as either 0 or 1.
+func SetBool(key string, value bool) error {
+	return std.SetBool(key, value)
+}
+
+// SetInts updates the value of a sysctl made of whitespace-separated
+// integers, such as net.ipv4.tcp_rmem.
+func SetInts(key string, values []int) error {
+	return std.SetInts(key, values)
+}
+
+// SetVerified updates the value of a sysctl and reads it back to verify
+// that it was applied. This is useful because the kernel may silently
+// clamp or rewrite some values.
+// Values are compared regardless of differences in whitespace.
+// If the value read back differs, a *MismatchError is returned.
+func SetVerified(key, value string) error {
+	return std.SetVerified(key, value)
+}
+
 // LoadConfigAndApply sets sysctl values from a list of sysctl configuration files.
 // The values in the rightmost files take priority.
 // If no file is specified, values are read from /etc/sysctl.conf.
+// See ApplyConfig for details on how values are applied.
 func LoadConfigAndApply(files ...string) error {
 	return std.LoadConfigAndApply(files...)
 }
+
+// LoadConfigAndApplyWith is like LoadConfigAndApply, but values are
+// applied with the given options, as in ApplyConfig.
+func LoadConfigAndApplyWith(files []string, opts ...ApplyOption) error {
+	return std.LoadConfigAndApplyWith(files, opts...)
+}
+
+// LoadConfigAndApplyContext is like LoadConfigAndApply, but it checks ctx
+// between sysctls. If ctx is done before all values are set, it stops
+// and returns a *CanceledError.
+func LoadConfigAndApplyContext(ctx context.Context, files ...string) error {
+	return std.LoadConfigAndApplyContext(ctx, files...)
+}
+
+// ApplyConfig sets sysctl values from a configuration, in the order
+// returned by Config.Effective.
+// Failures to set keys prefixed with "-" in the configuration are ignored.
+// Keys containing glob patterns are expanded against the existing sysctls,
+// with explicit settings taking precedence over glob matches.
+func ApplyConfig(config *Config, opts ...ApplyOption) error {
+	return std.ApplyConfig(config, opts...)
+}
+
+// ApplyConfigContext is like ApplyConfig, but it checks ctx between
+// sysctls. If ctx is done before all values are set, it stops and
+// returns a *CanceledError.
+func ApplyConfigContext(ctx context.Context, config *Config, opts ...ApplyOption) error {
+	return std.ApplyConfigContext(ctx, config, opts...)
+}
+
+// LoadSystemConfigAndApply sets sysctl values from all system configuration
+// files, as returned by SystemConfigFiles.
+// This is equivalent to what systemd-sysctl does on boot.
+func LoadSystemConfigAndApply() error {
+	return std.LoadSystemConfigAndApply()
+}
+
+// PlanConfig returns the changes required to apply a configuration without
+// writing anything. Sysctls whose current value already matches
+// the configuration are not included.
+// Values are compared regardless of differences in whitespace.
+func PlanConfig(config *Config) (*Plan, error) {
+	return std.PlanConfig(config)
+}
+
+// ApplyPlan applies the changes of a plan, in order.
+// Failures to set keys prefixed with "-" in the configuration are ignored.
+func ApplyPlan(plan *Plan, opts ...ApplyOption) error {
+	return std.ApplyPlan(plan, opts...)
+}
+
+// ApplyPlanContext is like ApplyPlan, but it checks ctx between sysctls.
+// If ctx is done before all values are set, it stops and returns
+// a *CanceledError.
+func ApplyPlanContext(ctx context.Context, plan *Plan, opts ...ApplyOption) error {
+	return std.ApplyPlanContext(ctx, plan, opts...)
+}
+
+// TakeSnapshot returns the current values of all readable and writable
+// sysctls, excluding volatile sysctls.
+// See Client.TakeSnapshot for details.
+func TakeSnapshot() (*Snapshot, error) {
+	return std.TakeSnapshot()
+}
+
+// Restore sets all sysctls to the values recorded in a snapshot.
+// See Client.Restore for details.
+func Restore(s *Snapshot, opts ...ApplyOption) error {
+	return std.Restore(s, opts...)
+}
@@ -0,0 +1,248 @@
+// Package sysctltest provides an in-memory implementation of
+// sysctl.Interface for testing code reading and writing sysctls.
+package sysctltest
+
+import (
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+// Op is an operation on a sysctl.
+type Op string
+
+// Operations on sysctls.
+const (
+	// OpGet is the operation of reading a sysctl.
+	OpGet Op = "get"
+	// OpSet is the operation of writing a sysctl.
+	OpSet Op = "set"
+)
+
+// Write is a write to a sysctl recorded by a Fake.
+type Write struct {
+	// Key is the sysctl key, in canonical form.
+	Key string
+	// Value is the value written.
+	Value string
+	// Err is the error returned by the write, if any.
+	Err error
+}
+
+// access is the access mode of a sysctl
+type access int
+
+const (
+	readWrite access = iota
+	readOnly
+	writeOnly
+)
+
+// errKey identifies the errors injected for an operation on a sysctl
+type errKey struct {
+	key string
+	op  Op
+}
+
+// Fake is an in-memory implementation of sysctl.Interface.
+//
+// Since it embeds a *sysctl.Client backed by in-memory values, it behaves
+// as a Client, including for applying configurations, and returns the same
+// errors. For example, reading a missing sysctl returns a *sysctl.KeyError
+// matching sysctl.ErrNotExist. It is safe for concurrent use.
+type Fake struct {
+	*sysctl.Client
+
+	mu     sync.Mutex
+	values map[string]string
+	access map[string]access
+	errs   map[errKey]error
+	writes []Write
+}
+
+var _ sysctl.Interface = &Fake{}
+
+// New returns a new Fake with the given sysctl values.
+// Keys can be in any form accepted by sysctl.CanonicalKey.
+func New(values map[string]string) *Fake {
+	f := &Fake{
+		values: make(map[string]string, len(values)),
+		access: make(map[string]access),
+		errs:   make(map[errKey]error),
+	}
+	for k, v := range values {
+		f.values[sysctl.CanonicalKey(k)] = v
+	}
+	f.Client = sysctl.NewBackendClient(backend{f})
+	return f
+}
+
+// NewFromConfig returns a new Fake with the sysctl values that take
+// effect in a configuration, as returned by Config.Map.
+// Keys containing glob patterns are ignored.
+func NewFromConfig(config *sysctl.Config) *Fake {
+	values := config.Map()
+	for k := range values {
+		if strings.ContainsAny(k, "*?[") {
+			delete(values, k)
+		}
+	}
+	return New(values)
+}
+
+// SetReadOnly marks sysctls as read-only, so that writing them fails
+// with an error matching sysctl.ErrPermission.
+func (f *Fake) SetReadOnly(keys ...string) {
+	f.setAccess(readOnly, keys)
+}
+
+// SetWriteOnly marks sysctls as write-only, so that reading them fails
+// with an error matching sysctl.ErrPermission.
+func (f *Fake) SetWriteOnly(keys ...string) {
+	f.setAccess(writeOnly, keys)
+}
+
+func (f *Fake) setAccess(a access, keys []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, k := range keys {
+		f.access[sysctl.CanonicalKey(k)] = a
+	}
+}
+
+// Remove removes sysctls, so that accessing them fails with an error
+// matching sysctl.ErrNotExist.
+func (f *Fake) Remove(keys ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, k := range keys {
+		k = sysctl.CanonicalKey(k)
+		delete(f.values, k)
+		delete(f.access, k)
+	}
+}
+
+// InjectError makes all subsequent operations of the given type on a
+// sysctl fail with err, such as syscall.EPERM, syscall.EINVAL or
+// syscall.EBUSY. A nil error removes a previously injected error.
+// Failed writes are still recorded.
+func (f *Fake) InjectError(key string, op Op, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	k := errKey{key: sysctl.CanonicalKey(key), op: op}
+	if err == nil {
+		delete(f.errs, k)
+		return
+	}
+	f.errs[k] = err
+}
+
+// Writes returns all writes attempted so far, including failed ones,
+// in the order in which they were attempted.
+func (f *Fake) Writes() []Write {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Write(nil), f.writes...)
+}
+
+// ResetWrites discards all writes recorded so far.
+func (f *Fake) ResetWrites() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.writes = nil
+}
+
+// Values returns the current values of all sysctls, keyed by
+// canonical key, regardless of their access mode.
+func (f *Fake) Values() map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]string, len(f.values))
+	for k, v := range f.values {
+		out[k] = v
+	}
+	return out
+}
+
+// backend is the sysctl.Backend of a Fake
+type backend struct {
+	f *Fake
+}
+
+func (b backend) Read(key string) (string, error) {
+	f := b.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.errs[errKey{key: key, op: OpGet}]; err != nil {
+		return "", pathError("read", key, err)
+	}
+	v, ok := f.values[key]
+	switch {
+	case !ok:
+		return "", pathError("open", key, syscall.ENOENT)
+	case f.access[key] == writeOnly:
+		return "", pathError("open", key, syscall.EACCES)
+	}
+	return strings.TrimSpace(v), nil
+}
+
+func (b backend) Write(key, value string) error {
+	f := b.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	err := f.write(key, value)
+	f.writes = append(f.writes, Write{Key: key, Value: value, Err: err})
+	return err
+}
+
+func (f *Fake) write(key, value string) error {
+	if err := f.errs[errKey{key: key, op: OpSet}]; err != nil {
+		return pathError("write", key, err)
+	}
+	if _, ok := f.values[key]; !ok {
+		return pathError("open", key, syscall.ENOENT)
+	}
+	if f.access[key] == readOnly {
+		return pathError("open", key, syscall.EACCES)
+	}
+	f.values[key] = value
+	return nil
+}
+
+func (b backend) Keys() ([]string, error) {
+	f := b.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	keys := make([]string, 0, len(f.values))
+	for k := range f.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (b backend) Mode(key string) (fs.FileMode, error) {
+	f := b.f
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.values[key]; !ok {
+		return 0, pathError("stat", key, syscall.ENOENT)
+	}
+	switch f.access[key] {
+	case readOnly:
+		return 0o444, nil
+	case writeOnly:
+		return 0o200, nil
+	}
+	return 0o644, nil
+}
+
+// pathError returns an error for an operation on a sysctl,
+// as returned for operations on files
+func pathError(op, key string, err error) error {
+	return &fs.PathError{Op: op, Path: key, Err: err}
+}
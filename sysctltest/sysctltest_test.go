@@ -0,0 +1,129 @@
+package sysctltest
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	sysctl "github.com/lorenzosaino/go-sysctl"
+)
+
+func TestFake(t *testing.T) {
+	f := New(map[string]string{
+		"net.ipv4.ip_forward": "0",
+		"net/ipv4/tcp_rmem":   "4096\t131072\t6291456",
+		"kernel.osrelease":    "6.1.0",
+		"vm.drop_caches":      "0",
+		"kernel.pid_max":      "32768",
+	})
+	f.SetReadOnly("kernel.osrelease")
+	f.SetWriteOnly("vm.drop_caches")
+	f.Remove("kernel.pid_max")
+	f.InjectError("net.ipv4.tcp_rmem", OpSet, syscall.EINVAL)
+
+	if v, err := f.GetInt("net.ipv4.ip_forward"); err != nil || v != 0 {
+		t.Fatalf("unexpected value: %d, err: %v", v, err)
+	}
+	if _, err := f.Get("kernel.pid_max"); !errors.Is(err, sysctl.ErrNotExist) {
+		t.Fatalf("expected error matching ErrNotExist, got: %v", err)
+	}
+	if _, err := f.Get("vm.drop_caches"); !errors.Is(err, sysctl.ErrPermission) {
+		t.Fatalf("expected error matching ErrPermission, got: %v", err)
+	}
+	if err := f.Set("kernel.osrelease", "x"); !errors.Is(err, sysctl.ErrPermission) {
+		t.Fatalf("expected error matching ErrPermission, got: %v", err)
+	}
+	if err := f.SetInts("net.ipv4.tcp_rmem", []int{1, 2, 3}); !errors.Is(err, sysctl.ErrInvalidValue) {
+		t.Fatalf("expected error matching ErrInvalidValue, got: %v", err)
+	}
+	if err := f.SetBool("net.ipv4.ip_forward", true); err != nil {
+		t.Fatalf("could not set value: %v", err)
+	}
+	if err := f.Set("vm.drop_caches", "3"); err != nil {
+		t.Fatalf("could not set value: %v", err)
+	}
+
+	all, err := f.GetAll()
+	if err != nil {
+		t.Fatalf("could not get all values: %v", err)
+	}
+	expectedAll := map[string]string{
+		"net.ipv4.ip_forward": "1",
+		"net.ipv4.tcp_rmem":   "4096\t131072\t6291456",
+		"kernel.osrelease":    "6.1.0",
+	}
+	if diff := cmp.Diff(expectedAll, all); diff != "" {
+		t.Fatalf("unexpected values (-want +got):\n%s", diff)
+	}
+	// sysctls skipped by GetAll are reported by WalkPattern
+	unreadable := make(map[string]error)
+	err = f.WalkPattern("", func(key, value string, err error) error {
+		if err != nil {
+			unreadable[key] = err
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("could not walk sysctls: %v", err)
+	}
+	if len(unreadable) != 1 || !errors.Is(unreadable["vm.drop_caches"], sysctl.ErrPermission) {
+		t.Fatalf("unexpected unreadable sysctls: %v", unreadable)
+	}
+
+	writes := []Write{
+		{Key: "kernel.osrelease", Value: "x", Err: syscall.EACCES},
+		{Key: "net.ipv4.tcp_rmem", Value: "1 2 3", Err: syscall.EINVAL},
+		{Key: "net.ipv4.ip_forward", Value: "1"},
+		{Key: "vm.drop_caches", Value: "3"},
+	}
+	if diff := cmp.Diff(writes, f.Writes(), cmp.Comparer(func(a, b error) bool {
+		return errors.Is(a, b) || errors.Is(b, a)
+	})); diff != "" {
+		t.Fatalf("unexpected writes (-want +got):\n%s", diff)
+	}
+	f.ResetWrites()
+	if len(f.Writes()) != 0 {
+		t.Fatal("writes not reset")
+	}
+	if v := f.Values()["vm.drop_caches"]; v != "3" {
+		t.Fatalf("unexpected value of write-only sysctl: %s", v)
+	}
+}
+
+func TestFakeApplyConfig(t *testing.T) {
+	f := NewFromConfig(&sysctl.Config{
+		Entries: []sysctl.Entry{
+			{Key: "net.ipv4.conf.all.rp_filter", Value: "0"},
+			{Key: "net.ipv4.conf.eth0.rp_filter", Value: "0"},
+			{Key: "net.ipv4.conf.*.rp_filter", Value: "0"},
+			{Key: "net.ipv4.ip_forward", Value: "0"},
+		},
+	})
+	f.InjectError("net.ipv4.ip_forward", OpSet, syscall.EBUSY)
+	err := f.ApplyConfig(&sysctl.Config{
+		Entries: []sysctl.Entry{
+			{Key: "net.ipv4.conf.*.rp_filter", Value: "2"},
+			{Key: "net.ipv4.ip_forward", Value: "1"},
+		},
+	}, sysctl.WithRollback())
+	var rerr *sysctl.RollbackError
+	if !errors.As(err, &rerr) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !errors.Is(err, syscall.EBUSY) {
+		t.Fatalf("expected error matching EBUSY, got: %v", err)
+	}
+	writes := []Write{
+		{Key: "net.ipv4.conf.all.rp_filter", Value: "2"},
+		{Key: "net.ipv4.conf.eth0.rp_filter", Value: "2"},
+		{Key: "net.ipv4.ip_forward", Value: "1"},
+		{Key: "net.ipv4.conf.eth0.rp_filter", Value: "0"},
+		{Key: "net.ipv4.conf.all.rp_filter", Value: "0"},
+	}
+	if diff := cmp.Diff(writes, f.Writes(), cmpopts.IgnoreFields(Write{}, "Err")); diff != "" {
+		t.Fatalf("unexpected writes (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,99 @@
+package sysctl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errNotBool = errors.New("value must be either 0 or 1")
+
+// GetInt returns the value of a sysctl as an int.
+// If the value cannot be converted, a *ValueError is returned.
+func (c *Client) GetInt(key string) (int, error) {
+	val, err := c.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, &ValueError{Key: key, Value: val, Type: "int", Err: err}
+	}
+	return i, nil
+}
+
+// GetUint64 returns the value of a sysctl as an uint64.
+// If the value cannot be converted, a *ValueError is returned.
+func (c *Client) GetUint64(key string) (uint64, error) {
+	val, err := c.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseUint(val, 10, 64)
+	if err != nil {
+		return 0, &ValueError{Key: key, Value: val, Type: "uint64", Err: err}
+	}
+	return i, nil
+}
+
+// GetBool returns the value of a sysctl as a bool.
+// The value must be either 0 or 1, otherwise a *ValueError is returned.
+func (c *Client) GetBool(key string) (bool, error) {
+	val, err := c.Get(key)
+	if err != nil {
+		return false, err
+	}
+	switch val {
+	case "0":
+		return false, nil
+	case "1":
+		return true, nil
+	}
+	return false, &ValueError{Key: key, Value: val, Type: "bool", Err: errNotBool}
+}
+
+// GetInts returns the value of a sysctl made of whitespace-separated
+// integers, such as net.ipv4.tcp_rmem, as a slice of ints.
+// If the value cannot be converted, a *ValueError is returned.
+func (c *Client) GetInts(key string) ([]int, error) {
+	val, err := c.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(val)
+	out := make([]int, len(fields))
+	for i, f := range fields {
+		if out[i], err = strconv.Atoi(f); err != nil {
+			return nil, &ValueError{Key: key, Value: val, Type: "[]int", Err: err}
+		}
+	}
+	return out, nil
+}
+
+// SetInt updates the value of a sysctl to an int.
+func (c *Client) SetInt(key string, value int) error {
+	return c.Set(key, strconv.Itoa(value))
+}
+
+// SetUint64 updates the value of a sysctl to an uint64.
+func (c *Client) SetUint64(key string, value uint64) error {
+	return c.Set(key, strconv.FormatUint(value, 10))
+}
+
+// SetBool updates the value of a sysctl to a bool, written as either 0 or 1.
+func (c *Client) SetBool(key string, value bool) error {
+	if value {
+		return c.Set(key, "1")
+	}
+	return c.Set(key, "0")
+}
+
+// SetInts updates the value of a sysctl made of whitespace-separated
+// integers, such as net.ipv4.tcp_rmem.
+func (c *Client) SetInts(key string, values []int) error {
+	fields := make([]string, len(values))
+	for i, v := range values {
+		fields[i] = strconv.Itoa(v)
+	}
+	return c.Set(key, strings.Join(fields, " "))
+}
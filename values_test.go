@@ -0,0 +1,139 @@
+package sysctl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func newValuesTestClient(t *testing.T) *Client {
+	t.Helper()
+	path := t.TempDir()
+	createConfigFiles(t, path, map[string]string{
+		"int":      "-42",
+		"uint64":   "18446744073709551615",
+		"bool":     "1",
+		"ints":     "4096\t131072\t6291456",
+		"string":   "example.com",
+		"bad_bool": "2",
+	})
+	cl, err := NewClient(path)
+	if err != nil {
+		t.Fatalf("could not create client: %v", err)
+	}
+	return cl
+}
+
+func TestClientGetTyped(t *testing.T) {
+	cases := []struct {
+		name     string
+		get      func(*Client, string) (interface{}, error)
+		key      string
+		expected interface{}
+		ok       bool
+	}{
+		{
+			name:     "int",
+			get:      func(c *Client, k string) (interface{}, error) { return c.GetInt(k) },
+			key:      "int",
+			expected: -42,
+			ok:       true,
+		},
+		{
+			name: "int invalid",
+			get:  func(c *Client, k string) (interface{}, error) { return c.GetInt(k) },
+			key:  "string",
+		},
+		{
+			name:     "uint64",
+			get:      func(c *Client, k string) (interface{}, error) { return c.GetUint64(k) },
+			key:      "uint64",
+			expected: uint64(18446744073709551615),
+			ok:       true,
+		},
+		{
+			name: "uint64 negative",
+			get:  func(c *Client, k string) (interface{}, error) { return c.GetUint64(k) },
+			key:  "int",
+		},
+		{
+			name:     "bool",
+			get:      func(c *Client, k string) (interface{}, error) { return c.GetBool(k) },
+			key:      "bool",
+			expected: true,
+			ok:       true,
+		},
+		{
+			name: "bool invalid",
+			get:  func(c *Client, k string) (interface{}, error) { return c.GetBool(k) },
+			key:  "bad_bool",
+		},
+		{
+			name:     "ints",
+			get:      func(c *Client, k string) (interface{}, error) { return c.GetInts(k) },
+			key:      "ints",
+			expected: []int{4096, 131072, 6291456},
+			ok:       true,
+		},
+		{
+			name: "ints invalid",
+			get:  func(c *Client, k string) (interface{}, error) { return c.GetInts(k) },
+			key:  "string",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cl := newValuesTestClient(t)
+			got, err := c.get(cl, c.key)
+			if c.ok && err != nil {
+				t.Fatalf("could not get %s: %v", c.key, err)
+			}
+			if !c.ok && err == nil {
+				t.Fatal("expected error but it succeeded")
+			}
+			if err != nil {
+				t.Logf("err: %v", err)
+				var verr *ValueError
+				if !errors.As(err, &verr) {
+					t.Fatalf("unexpected error type: %T", err)
+				}
+				return
+			}
+			if diff := cmp.Diff(c.expected, got); diff != "" {
+				t.Fatalf("unexpected output (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientSetTyped(t *testing.T) {
+	cl := newValuesTestClient(t)
+	if err := cl.SetInt("int", 7); err != nil {
+		t.Fatalf("could not set int: %v", err)
+	}
+	if err := cl.SetUint64("uint64", 1<<63); err != nil {
+		t.Fatalf("could not set uint64: %v", err)
+	}
+	if err := cl.SetBool("bool", false); err != nil {
+		t.Fatalf("could not set bool: %v", err)
+	}
+	if err := cl.SetInts("ints", []int{1, 2, 3}); err != nil {
+		t.Fatalf("could not set ints: %v", err)
+	}
+	expected := map[string]string{
+		"int":    "7",
+		"uint64": "9223372036854775808",
+		"bool":   "0",
+		"ints":   "1 2 3",
+	}
+	for k, v := range expected {
+		got, err := cl.Get(k)
+		if err != nil {
+			t.Fatalf("could not get key %s: %v", k, err)
+		}
+		if got != v {
+			t.Fatalf("got wrong value for key %s: expected: %s, got %s", k, v, got)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package sysctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp/syntax"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// maxReadWorkers is the maximum number of files read concurrently
+// while scanning the sysctl tree
+const maxReadWorkers = 16
+
+// patternPrefix returns the literal prefix of the keys matched by
+// a POSIX extended regular expression anchored at the beginning,
+// e.g. net.ipv4.conf.eth0. for ^net\.ipv4\.conf\.eth0\.
+// It returns an empty string if the pattern is not anchored or has
+// no literal prefix.
+func patternPrefix(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.POSIX)
+	if err != nil {
+		return ""
+	}
+	re = re.Simplify()
+	if re.Op != syntax.OpConcat || len(re.Sub) < 2 {
+		return ""
+	}
+	switch re.Sub[0].Op {
+	case syntax.OpBeginText, syntax.OpBeginLine:
+	default:
+		return ""
+	}
+	var b strings.Builder
+	for _, sub := range re.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		b.WriteString(string(sub.Rune))
+	}
+	return b.String()
+}
+
+// walkKeys returns the keys of all sysctls in the tree of fsys, along with
+// the paths of their files, skipping directories whose keys cannot start
+// with prefix. Keys are returned in the order in which fs.WalkDir
+// visits their files.
+// If ctx is done before the walk completes, a *CanceledError is returned.
+func walkKeys(ctx context.Context, fsys fs.FS, prefix string) (keys, paths []string, err error) {
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error accessing sysctl path: %w", err)
+		}
+		if err := ctx.Err(); err != nil {
+			return &CanceledError{Op: "list", Done: len(keys), Total: -1, Err: err}
+		}
+		if path == "." {
+			return nil
+		}
+		key := pathToKey(path)
+		if d.IsDir() {
+			// keys of files in this directory start with the key
+			// of the directory followed by a separator
+			dirKey := key + "."
+			if !strings.HasPrefix(dirKey, prefix) && !strings.HasPrefix(prefix, dirKey) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, paths, nil
+}
+
+// readWorkers returns the number of files that the client can
+// read concurrently
+func (c *Client) readWorkers() int {
+	if c.run != nil {
+		// files must be opened from the thread running
+		// file operations, which joined the namespaces of
+		// the client
+		return 1
+	}
+	if c.workers > 0 {
+		return c.workers
+	}
+	return min(runtime.GOMAXPROCS(0), maxReadWorkers)
+}
+
+// readFiles reads the files at the given paths of root using up to
+// the given number of concurrent workers. Files that cannot be opened
+// or read, e.g. for lack of permissions, are reported in errs as
+// *KeyError of the corresponding key, while other errors are returned as
+// err, choosing the one of the first failing file.
+// If ctx is done before all files are read, no more files are read and
+// a *CanceledError is returned.
+func readFiles(ctx context.Context, root *os.Root, keys, paths []string, workers int) (vals []string, errs []error, err error) {
+	vals = make([]string, len(paths))
+	errs = make([]error, len(paths))
+	fatal := make([]error, len(paths))
+	read := func(i int) {
+		val, err := readFile(root, paths[i])
+		if err != nil {
+			err = &KeyError{Key: keys[i], Op: "get", Err: err}
+			var pathError *os.PathError
+			if errors.As(err, &pathError) {
+				switch pathError.Op {
+				case "open", "openat", "read":
+					// this occurs if the file is not readable,
+					// e.g. for lack of permissions, which only
+					// affects the sysctl of the file
+					errs[i] = err
+					return
+				}
+			}
+			fatal[i] = err
+			return
+		}
+		vals[i] = val
+	}
+	canceled := func(done int, err error) error {
+		return &CanceledError{Op: "get", Done: done, Total: len(paths), Err: err}
+	}
+	if workers <= 1 {
+		for i := range paths {
+			if err := ctx.Err(); err != nil {
+				return nil, nil, canceled(i, err)
+			}
+			if read(i); fatal[i] != nil {
+				return nil, nil, fatal[i]
+			}
+		}
+		return vals, errs, nil
+	}
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				read(i)
+			}
+		}()
+	}
+	sent := 0
+	for ; sent < len(paths); sent++ {
+		if ctx.Err() != nil {
+			break
+		}
+		next <- sent
+	}
+	close(next)
+	wg.Wait()
+	if sent < len(paths) {
+		return nil, nil, canceled(sent, ctx.Err())
+	}
+	for _, err := range fatal {
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return vals, errs, nil
+}
@@ -0,0 +1,205 @@
+package sysctl
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPatternPrefix(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		expected string
+	}{
+		{pattern: "", expected: ""},
+		{pattern: "net", expected: ""},
+		{pattern: "^", expected: ""},
+		{pattern: "^net", expected: "net"},
+		{pattern: `^net\.ipv4\.conf\.eth0\.`, expected: "net.ipv4.conf.eth0."},
+		{pattern: `^net\.ipv4\.conf\.eth0\.rp_filter$`, expected: "net.ipv4.conf.eth0.rp_filter"},
+		{pattern: "^net.ipv4", expected: "net"},
+		{pattern: `^net\.ipv[46]\.`, expected: "net.ipv"},
+		{pattern: `^net\.ipv4\.tcp_(r|w)mem`, expected: "net.ipv4.tcp_"},
+		{pattern: `^kernel\.|^vm\.`, expected: ""},
+		{pattern: `^(net)\.`, expected: ""},
+		{pattern: "[[", expected: ""},
+	}
+	for _, c := range cases {
+		t.Run(c.pattern, func(t *testing.T) {
+			if got := patternPrefix(c.pattern); got != c.expected {
+				t.Fatalf("expected: %q. Got: %q", c.expected, got)
+			}
+		})
+	}
+}
+
+func TestWalkKeys(t *testing.T) {
+	fsys := fstest.MapFS{
+		"kernel/hostname":                  {},
+		"net/core/somaxconn":               {},
+		"net/ipv4/conf/eth0/rp_filter":     {},
+		"net/ipv4/conf/eth0.100/rp_filter": {},
+		"net/ipv4/conf/eth1/rp_filter":     {},
+		"net/ipv4/ip_forward":              {},
+	}
+	cases := []struct {
+		name   string
+		prefix string
+		keys   []string
+		paths  []string
+	}{
+		{
+			name: "all",
+			keys: []string{
+				"kernel.hostname",
+				"net.core.somaxconn",
+				"net.ipv4.conf.eth0.rp_filter",
+				"net.ipv4.conf.eth0/100.rp_filter",
+				"net.ipv4.conf.eth1.rp_filter",
+				"net.ipv4.ip_forward",
+			},
+			paths: []string{
+				"kernel/hostname",
+				"net/core/somaxconn",
+				"net/ipv4/conf/eth0/rp_filter",
+				"net/ipv4/conf/eth0.100/rp_filter",
+				"net/ipv4/conf/eth1/rp_filter",
+				"net/ipv4/ip_forward",
+			},
+		},
+		{
+			name:   "directory",
+			prefix: "net.ipv4.conf.eth0.",
+			keys:   []string{"net.ipv4.conf.eth0.rp_filter"},
+			paths:  []string{"net/ipv4/conf/eth0/rp_filter"},
+		},
+		{
+			name:   "partial component",
+			prefix: "net.ipv4.conf.eth",
+			keys: []string{
+				"net.ipv4.conf.eth0.rp_filter",
+				"net.ipv4.conf.eth0/100.rp_filter",
+				"net.ipv4.conf.eth1.rp_filter",
+			},
+			paths: []string{
+				"net/ipv4/conf/eth0/rp_filter",
+				"net/ipv4/conf/eth0.100/rp_filter",
+				"net/ipv4/conf/eth1/rp_filter",
+			},
+		},
+		{
+			name:   "file",
+			prefix: "net.ipv4.ip_forward",
+			keys:   []string{"net.ipv4.ip_forward"},
+			paths:  []string{"net/ipv4/ip_forward"},
+		},
+		{
+			name:   "none",
+			prefix: "vm.",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			keys, paths, err := walkKeys(context.Background(), fsys, c.prefix)
+			if err != nil {
+				t.Fatalf("could not walk keys: %v", err)
+			}
+			if diff := cmp.Diff(c.keys, keys); diff != "" {
+				t.Fatalf("unexpected keys (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(c.paths, paths); diff != "" {
+				t.Fatalf("unexpected paths (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestClientGetPatternWorkers(t *testing.T) {
+	path := newSyntheticTree(t, 20, 10)
+	var expected map[string]string
+	for _, workers := range []int{1, 2, 8} {
+		cl, err := NewClient(path)
+		if err != nil {
+			t.Fatalf("could not create client: %v", err)
+		}
+		cl.workers = workers
+		got, err := cl.GetPattern(`^net\.ipv4\.conf\.eth1`)
+		if err != nil {
+			t.Fatalf("could not get values: %v", err)
+		}
+		if len(got) != 11*10 {
+			t.Fatalf("unexpected number of values: %d", len(got))
+		}
+		if expected == nil {
+			expected = got
+			continue
+		}
+		if diff := cmp.Diff(expected, got); diff != "" {
+			t.Fatalf("unexpected output with %d workers (-want +got):\n%s", workers, diff)
+		}
+	}
+}
+
+// newSyntheticTree creates a sysctl tree resembling the one of a host with
+// the given number of network interfaces, each with the given number
+// of sysctls, and returns its path
+func newSyntheticTree(tb testing.TB, ifaces, files int) string {
+	tb.Helper()
+	path := tb.TempDir()
+	for i := range ifaces {
+		dir := filepath.Join(path, "net", "ipv4", "conf", fmt.Sprintf("eth%d", i))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatalf("could not create directory: %v", err)
+		}
+		for j := range files {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%d", j)), []byte(fmt.Sprintf("%d\n", j)), 0o644); err != nil {
+				tb.Fatalf("could not create file: %v", err)
+			}
+		}
+	}
+	for _, dir := range []string{"kernel", "vm", "fs"} {
+		if err := os.MkdirAll(filepath.Join(path, dir), 0o755); err != nil {
+			tb.Fatalf("could not create directory: %v", err)
+		}
+		for j := range files {
+			if err := os.WriteFile(filepath.Join(path, dir, fmt.Sprintf("f%d", j)), []byte("value\n"), 0o644); err != nil {
+				tb.Fatalf("could not create file: %v", err)
+			}
+		}
+	}
+	return path
+}
+
+func BenchmarkClientGetPattern(b *testing.B) {
+	path := newSyntheticTree(b, 2000, 30)
+	cases := []struct {
+		name    string
+		pattern string
+		workers int
+	}{
+		{name: "all/sequential", pattern: "", workers: 1},
+		{name: "all/concurrent", pattern: ""},
+		{name: "anchored/sequential", pattern: `^net\.ipv4\.conf\.eth1\.`, workers: 1},
+		{name: "anchored/concurrent", pattern: `^net\.ipv4\.conf\.eth1\.`},
+		{name: "unanchored/concurrent", pattern: `net\.ipv4\.conf\.eth1\.`},
+	}
+	for _, c := range cases {
+		b.Run(c.name, func(b *testing.B) {
+			cl, err := NewClient(path)
+			if err != nil {
+				b.Fatalf("could not create client: %v", err)
+			}
+			cl.workers = c.workers
+			for b.Loop() {
+				if _, err := cl.GetPattern(c.pattern); err != nil {
+					b.Fatalf("could not get values: %v", err)
+				}
+			}
+		})
+	}
+}